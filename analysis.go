@@ -0,0 +1,188 @@
+package main
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/dpetersen/language-learning/dialect"
+	"github.com/dpetersen/language-learning/frequency"
+	"github.com/dpetersen/language-learning/gpt"
+	"github.com/dpetersen/language-learning/lemma"
+	"github.com/dpetersen/language-learning/lingq"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// ReportNewWords logs every word in the story that isn't in our vocabulary,
+// along with how common it is, so we can tell whether the new words are
+// worth learning or the model just went off and used something obscure.
+func ReportNewWords(story gpt.Story, words []lingq.Word) {
+	list, err := frequency.Load(viper.GetString("language"))
+	if err != nil {
+		logrus.WithError(err).Warn("Can't rank new words")
+		return
+	}
+
+	// Match inflected forms too, otherwise every conjugation of a verb we
+	// know shows up as new.
+	terms := make([]string, 0, len(words))
+	for _, word := range words {
+		terms = append(terms, word.Term)
+	}
+	known := lemma.NewSet(viper.GetString("language"), terms)
+
+	total := 0
+	unknown := make(map[string]int)
+	for _, word := range storyWords(story) {
+		total++
+		if !known.Contains(word) {
+			unknown[word]++
+		}
+	}
+
+	newWords := make([]string, 0, len(unknown))
+	for word := range unknown {
+		newWords = append(newWords, word)
+	}
+	// Most common first, with anything that isn't on the list at all last.
+	sort.Slice(newWords, func(i, j int) bool {
+		rankI, okI := list.Rank(newWords[i])
+		rankJ, okJ := list.Rank(newWords[j])
+		if okI != okJ {
+			return okI
+		}
+		if rankI != rankJ {
+			return rankI < rankJ
+		}
+		return newWords[i] < newWords[j]
+	})
+
+	occurrences := 0
+	for _, word := range newWords {
+		occurrences += unknown[word]
+		fields := logrus.Fields{"word": word, "count": unknown[word]}
+		if rank, ok := list.Rank(word); ok {
+			fields["rank"] = rank
+		} else {
+			fields["rank"] = "unranked"
+		}
+		logrus.WithFields(fields).Info("New word")
+	}
+
+	if total > 0 {
+		logrus.WithFields(logrus.Fields{
+			"newWords":   len(newWords),
+			"percentNew": 100 * occurrences / total,
+		}).Info("Checked story against vocabulary")
+	}
+}
+
+// storyWords returns every word in the story's text, lower cased.
+func storyWords(story gpt.Story) []string {
+	return strings.FieldsFunc(strings.ToLower(story.Story), func(r rune) bool {
+		return !unicode.IsLetter(r)
+	})
+}
+
+// CheckLevel checks the story against the CEFR level and grammar targets we
+// asked for, either with another call to the model or with local heuristics
+// depending on openai.level_check. Misses are only warned about, since the
+// story is usually still worth reading.
+func CheckLevel(story *gpt.Story) {
+	if story.Level == "" && len(story.GrammarTargets) == 0 {
+		return
+	}
+
+	switch mode := viper.GetString("openai.level_check"); mode {
+	case "off":
+		return
+	case "heuristic":
+		story.LevelReport = gpt.EstimateLevel(*story, story.GrammarTargets)
+	case "model":
+		client := gpt.NewClient(viper.GetString("openai.api_key"), viper.GetString("openai.chat_model"))
+		report, err := client.CheckLevel(*story, story.GrammarTargets)
+		if err != nil {
+			logrus.WithError(err).Warn("Checking story level")
+			return
+		}
+		story.LevelReport = report
+	default:
+		logrus.WithField("mode", mode).Warn("Unknown level check, skipping it")
+		return
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"level":    story.LevelReport.Level,
+		"grammar":  story.LevelReport.Grammar,
+		"comments": story.LevelReport.Comments,
+	}).Info("Checked story level")
+	for _, problem := range story.LevelReport.Problems(story.Level, story.GrammarTargets) {
+		logrus.WithField("problem", problem).Warn("Story missed its level or grammar targets")
+	}
+}
+
+// CheckDialect lints the story for words and forms from a different region
+// than dialect.region, like "vosotros" in a story meant for Mexico.
+func CheckDialect(story gpt.Story) {
+	region := viper.GetString("dialect.region")
+	if region == "" {
+		return
+	}
+
+	profile, err := dialect.Load(viper.GetString("language"), region)
+	if err != nil {
+		logrus.WithError(err).Warn("Can't check dialect")
+		return
+	}
+
+	problems := profile.Lint(story.ToString())
+	for _, problem := range problems {
+		logrus.WithFields(logrus.Fields{
+			"word":   problem.Word,
+			"count":  problem.Count,
+			"prefer": problem.Prefer,
+		}).Warn("Story uses a word from another region")
+	}
+	logrus.WithFields(logrus.Fields{"region": region, "problems": len(problems)}).Info("Checked dialect")
+}
+
+// EvaluateStory has the evaluator score the story, and reports whether it
+// scored at least openai.evaluation.thresholds on everything. If the
+// evaluator itself fails we carry on as though the story passed, rather than
+// throw away a story that's probably fine.
+func EvaluateStory(client *gpt.Client, story *gpt.Story) bool {
+	logrus.Info("Evaluating story...")
+	evaluation, err := client.Evaluate(*story)
+	if err != nil {
+		logrus.WithError(err).Warn("Evaluating story")
+		return true
+	}
+	story.Evaluation = evaluation
+
+	logrus.WithFields(logrus.Fields{
+		"scores":   evaluation.Scores,
+		"comments": evaluation.Comments,
+	}).Info("Evaluated story")
+
+	problems := evaluationProblems(*story)
+	for _, problem := range problems {
+		logrus.WithField("problem", problem).Warn("Story scored below its threshold")
+	}
+	return len(problems) == 0
+}
+
+// evaluationProblems returns where the story's evaluation fell short of
+// openai.evaluation.thresholds, if it has one.
+func evaluationProblems(story gpt.Story) []string {
+	if story.Evaluation == nil {
+		return nil
+	}
+
+	var thresholds map[string]int
+	if err := viper.UnmarshalKey("openai.evaluation.thresholds", &thresholds); err != nil {
+		logrus.WithError(err).Warn("Reading evaluation thresholds, ignoring them")
+		return nil
+	}
+	return story.Evaluation.Problems(thresholds)
+}
@@ -0,0 +1,38 @@
+package audio
+
+import (
+	"fmt"
+
+	"github.com/dpetersen/language-learning/diskcache"
+)
+
+// Cache stores synthesized audio on disk, addressed by a hash of everything
+// that goes into the synthesis request. That way fixing a typo in one
+// paragraph only costs us that one paragraph the next time around.
+type Cache struct {
+	*diskcache.Cache
+}
+
+func NewCache(path string) *Cache {
+	return &Cache{Cache: diskcache.New(path, "")}
+}
+
+func cacheKey(ssml, voiceName string, rate float64, encoding string) string {
+	return diskcache.Key(ssml, voiceName, fmt.Sprint(rate), encoding)
+}
+
+// Get returns the cached audio for key, or nil if there isn't any.
+func (c *Cache) Get(key string) ([]byte, error) {
+	data, err := c.Cache.Get(key, 0)
+	if err != nil || data == nil {
+		return nil, err
+	}
+
+	// Bump the modification time so pruning by age leaves alone whatever we're
+	// still using.
+	if err := c.Touch(key); err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
@@ -2,14 +2,17 @@ package audio
 
 import (
 	"context"
-	"log"
-	"math/rand"
+	"encoding/base64"
+	"fmt"
+	"hash/fnv"
 	"strings"
+	"sync/atomic"
+	"time"
 
-	texttospeech "cloud.google.com/go/texttospeech/apiv1"
-	"cloud.google.com/go/texttospeech/apiv1/texttospeechpb"
 	"github.com/dpetersen/language-learning/gpt"
 	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	"golang.org/x/sync/errgroup"
 )
 
 const speakingRate = 0.8
@@ -25,41 +28,68 @@ var voiceNames = []string{
 
 type AudioClient struct{}
 
-func storyToSSML(story gpt.Story) string {
-	var result strings.Builder
-
-	result.WriteString("<speak>")
-	result.WriteString("<p>")
-	result.WriteString(story.Title)
-	result.WriteString("</p>")
-	result.WriteString(`<break time="2s"/>`)
-	logrus.WithField("paragraphs", len(strings.Split(story.Story, "\n"))).Debug("How many paragraphs?")
-	for _, paragraph := range strings.Split(story.Story, "\n") {
-		result.WriteString("<p>")
-		result.WriteString(paragraph)
-		result.WriteString("</p>")
-	}
-	result.WriteString(`<break time="3s"/>`)
-	result.WriteString("<p>")
-	result.WriteString("Preguntas:")
-	result.WriteString("</p>")
-	result.WriteString(`<break time="1s"/>`)
+func storyToSSML(story gpt.Story, p profile) ([]string, error) {
+	var ssml ssmlBuilder
+
+	ssml.paragraph(story.Title)
+	ssml.pause(2 * time.Second)
+	if p.readStory {
+		paragraphs := story.Paragraphs()
+		logrus.WithField("paragraphs", len(paragraphs)).Debug("How many paragraphs?")
+		for _, paragraph := range paragraphs {
+			ssml.paragraph(paragraph)
+		}
+		ssml.pause(3 * time.Second)
+	}
+	ssml.paragraph("Preguntas:")
+	ssml.pause(time.Second)
 	for _, question := range story.Questions {
-		result.WriteString("<p>")
-		result.WriteString(question.Question)
-		result.WriteString("</p>")
-		result.WriteString(`<break time="3s"/>`)
-		result.WriteString("<p>")
-		result.WriteString(question.Answer)
-		result.WriteString("</p>")
-		result.WriteString(`<break time="1s"/>`)
+		spoken := spokenQuestion(question)
+		ssml.paragraph(spoken)
+		ssml.pause(p.thinkTime(spoken))
+		if !p.readAnswers {
+			continue
+		}
+		switch p.answerCue {
+		case cueChime:
+			ssml.sound(p.chimeURL, p.cueText)
+		case cueSpoken:
+			ssml.paragraph(p.cueText)
+		}
+		ssml.paragraph(question.AnswerText())
+		ssml.pause(p.answerPause)
 	}
 
-	result.WriteString("</speak>")
+	documents, err := ssml.build()
+	if err != nil {
+		return nil, fmt.Errorf("building SSML: %v", err)
+	}
 
-	logrus.WithField("ssml", result.String()).Debug("Generated SSML")
+	logrus.WithField("ssml", documents).Debug("Generated SSML")
 
-	return result.String()
+	return documents, nil
+}
+
+// spokenQuestion reads out the question along with what kind it is and its
+// options, since a listener can't see the list. The blank in a cloze question
+// is read as a pause.
+func spokenQuestion(question gpt.Question) string {
+	var parts []string
+	if instruction := question.Instruction(); instruction != "" {
+		parts = append(parts, instruction)
+	}
+
+	if question.Kind() == gpt.QuestionCloze {
+		parts = append(parts, question.FillBlank("…"))
+	} else {
+		parts = append(parts, question.Question)
+	}
+
+	for i, option := range question.Options {
+		parts = append(parts, fmt.Sprintf("%s: %s.", strings.ToUpper(gpt.OptionLetter(i)), strings.TrimRight(option, ".")))
+	}
+
+	return strings.Join(parts, " ")
 }
 
 func NewAudioClient() *AudioClient {
@@ -67,37 +97,110 @@ func NewAudioClient() *AudioClient {
 }
 
 func (c *AudioClient) TextToSpeech(story gpt.Story) ([]byte, error) {
-	ctx := context.Background()
+	p, err := profileFromConfig()
+	if err != nil {
+		return nil, fmt.Errorf("loading audio profile: %v", err)
+	}
 
-	client, err := texttospeech.NewClient(ctx)
+	chunks, err := storyToSSML(story, p)
 	if err != nil {
-		log.Fatal(err)
-	}
-	defer client.Close()
-
-	resp, err := client.SynthesizeSpeech(
-		ctx,
-		&texttospeechpb.SynthesizeSpeechRequest{
-			Input: &texttospeechpb.SynthesisInput{
-				InputSource: &texttospeechpb.SynthesisInput_Ssml{Ssml: storyToSSML(story)},
-			},
-			Voice: &texttospeechpb.VoiceSelectionParams{
-				Name:         randomVoiceName(),
-				LanguageCode: "es-US",
-			},
-			AudioConfig: &texttospeechpb.AudioConfig{
-				SpeakingRate:  speakingRate,
-				AudioEncoding: texttospeechpb.AudioEncoding_MP3,
-			},
-		},
-	)
+		return nil, err
+	}
+
+	voiceName := voiceForStory(story)
+	s := &synthesizer{
+		cache:     NewCache(viper.GetString("audio.cache_path")),
+		limiter:   newRateLimiter(viper.GetInt("audio.requests_per_minute")),
+		voiceName: voiceName,
+	}
+	defer s.close()
+
+	// The same chunk can come up more than once, like the cue before every
+	// answer, so each distinct chunk is only synthesized once and then used
+	// wherever it's needed.
+	var distinct []string
+	slots := make([]int, len(chunks))
+	seen := make(map[string]int)
+	for i, chunk := range chunks {
+		slot, ok := seen[chunk]
+		if !ok {
+			slot = len(distinct)
+			seen[chunk] = slot
+			distinct = append(distinct, chunk)
+		}
+		slots[i] = slot
+	}
+
+	// Each worker writes only to its own slot, so the pieces come back in the
+	// order they were read no matter which one finishes first.
+	pieces := make([][]byte, len(distinct))
+	var finished atomic.Int32
+	group, ctx := errgroup.WithContext(context.Background())
+	group.SetLimit(max(viper.GetInt("audio.concurrency"), 1))
+	for i, chunk := range distinct {
+		i, chunk := i, chunk
+		group.Go(func() error {
+			// Once anything has failed there's no point starting more work.
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+
+			audio, cached, err := s.synthesize(ctx, chunk)
+			if err != nil {
+				return fmt.Errorf("synthesizing chunk %d: %v", i, err)
+			}
+			pieces[i] = audio
+
+			logrus.WithFields(logrus.Fields{
+				"chunk":    i,
+				"cached":   cached,
+				"finished": fmt.Sprintf("%d/%d", finished.Add(1), len(distinct)),
+			}).Info("Synthesized audio")
+			return nil
+		})
+	}
+	if err := group.Wait(); err != nil {
+		return nil, err
+	}
+
+	var result []byte
+	for _, slot := range slots {
+		result = append(result, stripID3(pieces[slot])...)
+	}
+
+	tags, err := storyToTags(story, voiceName)
 	if err != nil {
-		log.Fatal(err)
+		return nil, fmt.Errorf("building ID3 tags: %v", err)
+	}
+
+	return tags.prepend(result), nil
+}
+
+func storyToTags(story gpt.Story, voiceName string) (id3Tags, error) {
+	tags := id3Tags{
+		title:      story.Title,
+		album:      viper.GetString("series.name"),
+		artist:     voiceName,
+		comment:    story.Description,
+		transcript: story.ToString(),
+	}
+
+	if story.Thumbnail != "" {
+		cover, err := base64.StdEncoding.DecodeString(story.Thumbnail)
+		if err != nil {
+			return id3Tags{}, fmt.Errorf("decoding thumbnail: %v", err)
+		}
+		tags.coverPNG = cover
 	}
 
-	return resp.AudioContent, nil
+	return tags, nil
 }
 
-func randomVoiceName() string {
-	return voiceNames[rand.Intn(len(voiceNames))]
+// voiceForStory picks a voice based on the title rather than at random, so
+// that running the same story again uses the same voice and can be served out
+// of the cache.
+func voiceForStory(story gpt.Story) string {
+	hash := fnv.New32a()
+	hash.Write([]byte(story.Title))
+	return voiceNames[hash.Sum32()%uint32(len(voiceNames))]
 }
@@ -0,0 +1,126 @@
+package audio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"unicode/utf16"
+)
+
+// We write ID3v2.3 rather than 2.4 because that's what podcast apps and phones
+// reliably understand. Text is UTF-16 with a BOM, since 2.3 doesn't support
+// UTF-8 and we definitely need more than Latin-1.
+const (
+	id3Language      = "spa"
+	id3EncodingUTF16 = 0x01
+	id3FrontCover    = 0x03
+)
+
+type id3Tags struct {
+	title      string
+	album      string
+	artist     string
+	comment    string
+	transcript string
+	coverPNG   []byte
+}
+
+// prepend returns mp3 with an ID3v2.3 tag in front of it.
+func (t id3Tags) prepend(mp3 []byte) []byte {
+	var frames bytes.Buffer
+	writeTextFrame(&frames, "TIT2", t.title)
+	writeTextFrame(&frames, "TALB", t.album)
+	writeTextFrame(&frames, "TPE1", t.artist)
+	if t.comment != "" {
+		writeFrame(&frames, "COMM", languageFrameBody("", t.comment))
+	}
+	if t.transcript != "" {
+		writeFrame(&frames, "USLT", languageFrameBody("", t.transcript))
+	}
+	if len(t.coverPNG) > 0 {
+		var body bytes.Buffer
+		body.WriteByte(id3EncodingUTF16)
+		body.WriteString("image/png")
+		body.WriteByte(0)
+		body.WriteByte(id3FrontCover)
+		body.Write(utf16String(""))
+		body.Write(t.coverPNG)
+		writeFrame(&frames, "APIC", body.Bytes())
+	}
+
+	var result bytes.Buffer
+	result.WriteString("ID3")
+	result.Write([]byte{3, 0}) // version 2.3.0
+	result.WriteByte(0)        // no flags
+	result.Write(syncsafe(uint32(frames.Len())))
+	result.Write(frames.Bytes())
+	result.Write(mp3)
+
+	return result.Bytes()
+}
+
+func writeTextFrame(w *bytes.Buffer, id, text string) {
+	if text == "" {
+		return
+	}
+	body := append([]byte{id3EncodingUTF16}, utf16String(text)...)
+	writeFrame(w, id, body)
+}
+
+// languageFrameBody builds the shared layout of COMM and USLT frames: an
+// encoding, a language, a terminated description and then the text itself.
+func languageFrameBody(description, text string) []byte {
+	var body bytes.Buffer
+	body.WriteByte(id3EncodingUTF16)
+	body.WriteString(id3Language)
+	body.Write(utf16String(description))
+	body.Write(utf16String(text))
+	return body.Bytes()
+}
+
+func writeFrame(w *bytes.Buffer, id string, body []byte) {
+	w.WriteString(id)
+	// Unlike the tag header, frame sizes in 2.3 are plain big-endian integers.
+	_ = binary.Write(w, binary.BigEndian, uint32(len(body)))
+	w.Write([]byte{0, 0}) // no flags
+	w.Write(body)
+}
+
+// utf16String encodes s as little-endian UTF-16 with a BOM and a null
+// terminator.
+func utf16String(s string) []byte {
+	result := []byte{0xFF, 0xFE}
+	for _, unit := range utf16.Encode([]rune(s)) {
+		result = append(result, byte(unit), byte(unit>>8))
+	}
+	return append(result, 0, 0)
+}
+
+// stripID3 removes an ID3v2 tag from the start of mp3, if there is one, so
+// that pieces of audio can be joined without tags ending up in the middle.
+func stripID3(mp3 []byte) []byte {
+	if len(mp3) < 10 || string(mp3[:3]) != "ID3" {
+		return mp3
+	}
+
+	size := int(mp3[6])<<21 | int(mp3[7])<<14 | int(mp3[8])<<7 | int(mp3[9])
+	end := 10 + size
+	if mp3[5]&0x10 != 0 {
+		end += 10 // footer
+	}
+	if end > len(mp3) {
+		return mp3
+	}
+
+	return mp3[end:]
+}
+
+// syncsafe encodes n in the 7-bits-per-byte format the tag header uses, so
+// that no byte of the size can look like an MPEG sync.
+func syncsafe(n uint32) []byte {
+	return []byte{
+		byte(n>>21) & 0x7F,
+		byte(n>>14) & 0x7F,
+		byte(n>>7) & 0x7F,
+		byte(n) & 0x7F,
+	}
+}
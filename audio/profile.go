@@ -0,0 +1,98 @@
+package audio
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+const (
+	profileStandard = "standard"
+	profileQuiz     = "quiz"
+	profileReview   = "review"
+
+	cueNone   = "none"
+	cueChime  = "chime"
+	cueSpoken = "spoken"
+)
+
+// profile controls what gets read aloud and how long we pause around the
+// questions. The standard profile matches what we've always done: the whole
+// story, then each question with a short pause and its answer. The quiz
+// profile gives you time to actually answer out loud, and the review profile
+// skips the story entirely so you can run through the questions on a walk.
+type profile struct {
+	readStory   bool
+	readAnswers bool
+
+	thinkBase    time.Duration
+	thinkPerWord time.Duration
+	thinkMax     time.Duration
+	answerPause  time.Duration
+
+	answerCue string
+	cueText   string
+	chimeURL  string
+}
+
+func profileFromConfig() (profile, error) {
+	name := viper.GetString("audio.profile")
+	if name == "" || name == profileStandard {
+		return profile{
+			readStory:   true,
+			readAnswers: true,
+			thinkBase:   3 * time.Second,
+			thinkMax:    3 * time.Second,
+			answerPause: time.Second,
+			answerCue:   cueNone,
+		}, nil
+	}
+
+	p := profile{
+		readStory:    name == profileQuiz,
+		readAnswers:  viper.GetBool("audio.quiz.include_answers"),
+		thinkBase:    viper.GetDuration("audio.quiz.think_time_base"),
+		thinkPerWord: viper.GetDuration("audio.quiz.think_time_per_word"),
+		thinkMax:     viper.GetDuration("audio.quiz.think_time_max"),
+		answerPause:  viper.GetDuration("audio.quiz.answer_pause"),
+		answerCue:    viper.GetString("audio.quiz.answer_cue"),
+		cueText:      viper.GetString("audio.quiz.cue_text"),
+		chimeURL:     viper.GetString("audio.quiz.chime_url"),
+	}
+
+	switch name {
+	case profileQuiz:
+	case profileReview:
+		// Review without answers would just be a list of questions, which
+		// isn't much of a review.
+		p.readAnswers = true
+	default:
+		return profile{}, fmt.Errorf("unknown audio profile %q", name)
+	}
+
+	switch p.answerCue {
+	case cueNone, cueSpoken:
+	case cueChime:
+		// Google will only fetch the chime over HTTPS, so without a URL the best
+		// we can do is say something instead.
+		if p.chimeURL == "" {
+			p.answerCue = cueSpoken
+		}
+	default:
+		return profile{}, fmt.Errorf("unknown answer cue %q", p.answerCue)
+	}
+
+	return p, nil
+}
+
+// thinkTime scales the pause after a question with its length, since longer
+// questions take longer to work through.
+func (p profile) thinkTime(question string) time.Duration {
+	pause := p.thinkBase + time.Duration(len(strings.Fields(question)))*p.thinkPerWord
+	if p.thinkMax > 0 && pause > p.thinkMax {
+		return p.thinkMax
+	}
+	return pause
+}
@@ -0,0 +1,196 @@
+package audio
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Google rejects any request where the SSML is longer than this.
+const maxSSMLBytes = 5000
+
+// maxParagraphBytes is how much escaped text a single <p> can hold, which
+// leaves room for the <speak> and <p> tags and the pauses and sounds that
+// follow it in the same document.
+const maxParagraphBytes = maxSSMLBytes - 500
+
+// ssmlBuilder assembles <speak> documents out of paragraphs and pauses. The
+// model hands us all kinds of stray characters, so everything that goes in
+// here is escaped, and the finished documents are parsed back before we send
+// them anywhere.
+type ssmlBuilder struct {
+	// Each chunk starts with a paragraph and holds whatever follows it up to
+	// the next one.
+	chunks [][]string
+}
+
+// paragraph adds text as its own <p>, collapsing any whitespace. Paragraphs
+// that are empty once that's done are dropped, which takes care of the blank
+// lines the model likes to put between paragraphs. A paragraph too long for
+// a single request is split between sentences into several.
+func (b *ssmlBuilder) paragraph(text string) {
+	text = normalizeWhitespace(text)
+	if text == "" {
+		return
+	}
+	for _, part := range splitParagraph(escapeSSML(text), maxParagraphBytes) {
+		b.chunks = append(b.chunks, []string{"<p>" + part + "</p>"})
+	}
+}
+
+var sentenceEnd = regexp.MustCompile(`[.!?…]+["'»”)]*(?:&#34;|&#39;)* `)
+
+// splitParagraph splits escaped text into parts of at most limit bytes,
+// packing in as many whole sentences as fit. A sentence too long on its own
+// is split between words, and a word too long on its own between runes,
+// which at least keeps it from failing the whole story.
+func splitParagraph(text string, limit int) []string {
+	if len(text) <= limit {
+		return []string{text}
+	}
+
+	var sentences []string
+	start := 0
+	for _, match := range sentenceEnd.FindAllStringIndex(text, -1) {
+		sentences = append(sentences, text[start:match[1]])
+		start = match[1]
+	}
+	sentences = append(sentences, text[start:])
+
+	var parts []string
+	var current string
+	for _, sentence := range sentences {
+		if len(current)+len(sentence) <= limit {
+			current += sentence
+			continue
+		}
+		if current != "" {
+			parts = append(parts, strings.TrimSpace(current))
+			current = ""
+		}
+		for len(sentence) > limit {
+			cut := strings.LastIndex(sentence[:limit+1], " ")
+			if cut <= 0 {
+				cut = limit
+				// Don't break a rune or an escaped character in two.
+				for cut > 0 && !utf8.RuneStart(sentence[cut]) {
+					cut--
+				}
+				if amp := strings.LastIndex(sentence[:cut], "&"); amp >= 0 && !strings.Contains(sentence[amp:cut], ";") {
+					cut = amp
+				}
+			}
+			parts = append(parts, strings.TrimSpace(sentence[:cut]))
+			sentence = sentence[cut:]
+		}
+		current = sentence
+	}
+	if current = strings.TrimSpace(current); current != "" {
+		parts = append(parts, current)
+	}
+	return parts
+}
+
+func (b *ssmlBuilder) add(element string) {
+	if len(b.chunks) == 0 {
+		b.chunks = append(b.chunks, nil)
+	}
+	last := len(b.chunks) - 1
+	b.chunks[last] = append(b.chunks[last], element)
+}
+
+// pause adds a break. Google caps a single break at 10 seconds, so longer
+// pauses are strung together out of several.
+func (b *ssmlBuilder) pause(d time.Duration) {
+	for d > maxBreak {
+		b.add(fmt.Sprintf(`<break time="%dms"/>`, maxBreak.Milliseconds()))
+		d -= maxBreak
+	}
+	if d > 0 {
+		b.add(fmt.Sprintf(`<break time="%dms"/>`, d.Milliseconds()))
+	}
+}
+
+// sound plays the audio file at src, or speaks fallback if it can't be
+// fetched.
+func (b *ssmlBuilder) sound(src, fallback string) {
+	b.add(`<audio src="` + escapeSSML(src) + `">` + escapeSSML(normalizeWhitespace(fallback)) + "</audio>")
+}
+
+// build returns one <speak> document per <p>. Synthesizing them
+// separately keeps each request under Google's size limit and lets us cache
+// the audio for each one on its own.
+func (b *ssmlBuilder) build() ([]string, error) {
+	documents := make([]string, 0, len(b.chunks))
+	for i, chunk := range b.chunks {
+		document := "<speak>" + strings.Join(chunk, "") + "</speak>"
+		if err := validateSSML(document); err != nil {
+			return nil, fmt.Errorf("paragraph %d: %v", i, err)
+		}
+		documents = append(documents, document)
+	}
+	return documents, nil
+}
+
+const maxBreak = 10 * time.Second
+
+func normalizeWhitespace(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+func escapeSSML(s string) string {
+	var result bytes.Buffer
+	// Writing to a bytes.Buffer can't fail.
+	_ = xml.EscapeText(&result, []byte(s))
+	return result.String()
+}
+
+// validateSSML makes sure the document is well-formed XML with a single
+// <speak> root and fits in a single request.
+func validateSSML(document string) error {
+	if len(document) > maxSSMLBytes {
+		return fmt.Errorf("SSML is %d bytes, over the limit of %d", len(document), maxSSMLBytes)
+	}
+
+	decoder := xml.NewDecoder(strings.NewReader(document))
+	depth := 0
+	roots := 0
+	for {
+		token, err := decoder.Token()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("parsing SSML: %v", err)
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			if depth == 0 {
+				if t.Name.Local != "speak" {
+					return fmt.Errorf("unexpected root element <%s>", t.Name.Local)
+				}
+				roots++
+			}
+			depth++
+		case xml.EndElement:
+			depth--
+		case xml.CharData:
+			if depth == 0 && len(bytes.TrimSpace(t)) > 0 {
+				return errors.New("text outside of <speak>")
+			}
+		}
+	}
+
+	if roots != 1 {
+		return fmt.Errorf("expected a single <speak> element, found %d", roots)
+	}
+
+	return nil
+}
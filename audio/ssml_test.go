@@ -0,0 +1,130 @@
+package audio
+
+import (
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+func TestParagraphEscapesText(t *testing.T) {
+	var b ssmlBuilder
+	b.paragraph(`Tom & Jerry dijo "<hola>" y 'adiós'`)
+
+	documents, err := b.build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `<speak><p>Tom &amp; Jerry dijo &#34;&lt;hola&gt;&#34; y &#39;adiós&#39;</p></speak>`
+	if len(documents) != 1 || documents[0] != want {
+		t.Errorf("build() = %q, want [%q]", documents, want)
+	}
+}
+
+func TestParagraphNormalizesWhitespace(t *testing.T) {
+	var b ssmlBuilder
+	b.paragraph("  Había una vez\n\tun  gato. \n")
+	b.paragraph("\n\n")
+	b.paragraph(" \t ")
+
+	documents, err := b.build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"<speak><p>Había una vez un gato.</p></speak>"}
+	if strings.Join(documents, "\n") != strings.Join(want, "\n") {
+		t.Errorf("build() = %q, want %q", documents, want)
+	}
+	for _, document := range documents {
+		if strings.Contains(document, "<p></p>") {
+			t.Errorf("empty paragraph in %q", document)
+		}
+	}
+}
+
+func TestPauseSplitsLongBreaks(t *testing.T) {
+	var b ssmlBuilder
+	b.paragraph("Piensa.")
+	b.pause(25 * time.Second)
+
+	documents, err := b.build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `<speak><p>Piensa.</p><break time="10000ms"/><break time="10000ms"/><break time="5000ms"/></speak>`
+	if len(documents) != 1 || documents[0] != want {
+		t.Errorf("build() = %q, want [%q]", documents, want)
+	}
+}
+
+func TestParagraphSplitsOversizedParagraph(t *testing.T) {
+	sentence := `Ana dijo "sí" y caminó hacia la estación. `
+	text := strings.Repeat(sentence, 2*maxSSMLBytes/len(sentence))
+
+	var b ssmlBuilder
+	b.paragraph(text)
+	b.pause(3 * time.Second)
+
+	documents, err := b.build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(documents) < 2 {
+		t.Fatalf("build() = %d documents, want the paragraph split up", len(documents))
+	}
+
+	var spoken []string
+	for _, document := range documents {
+		part := strings.TrimPrefix(document, "<speak><p>")
+		part, _, _ = strings.Cut(part, "</p>")
+		if !strings.HasSuffix(part, ".") {
+			t.Errorf("part doesn't end at a sentence: %q", part[max(0, len(part)-40):])
+		}
+		spoken = append(spoken, part)
+	}
+	if got, want := strings.Join(spoken, " "), escapeSSML(normalizeWhitespace(text)); got != want {
+		t.Error("split paragraphs don't add back up to the original")
+	}
+	if last := documents[len(documents)-1]; !strings.HasSuffix(last, `<break time="3000ms"/></speak>`) {
+		t.Errorf("pause isn't after the last part: %q", last[max(0, len(last)-60):])
+	}
+}
+
+func TestSplitParagraphLongSentence(t *testing.T) {
+	text := strings.Repeat("palabra ", 20) + strings.Repeat("é", 30)
+	for _, part := range splitParagraph(text, 50) {
+		if len(part) > 50 {
+			t.Errorf("part is %d bytes, over the limit of 50", len(part))
+		}
+		if !utf8.ValidString(part) {
+			t.Errorf("part has a broken rune: %q", part)
+		}
+	}
+}
+
+func TestValidateSSML(t *testing.T) {
+	tests := map[string]struct {
+		document string
+		valid    bool
+	}{
+		"simple":         {"<speak><p>Hola.</p></speak>", true},
+		"whitespace":     {"\n<speak><p>Hola.</p></speak>\n", true},
+		"oversized":      {"<speak><p>" + strings.Repeat("a", maxSSMLBytes) + "</p></speak>", false},
+		"multiple roots": {"<speak><p>Hola.</p></speak><speak><p>Adiós.</p></speak>", false},
+		"stray text":     {"Hola <speak><p>Adiós.</p></speak>", false},
+		"trailing text":  {"<speak><p>Hola.</p></speak> adiós", false},
+		"wrong root":     {"<p>Hola.</p>", false},
+		"unescaped":      {"<speak><p>Tom & Jerry</p></speak>", false},
+		"unclosed":       {"<speak><p>Hola.</speak>", false},
+		"no root at all": {"", false},
+	}
+	for name, test := range tests {
+		err := validateSSML(test.document)
+		if test.valid && err != nil {
+			t.Errorf("%s: unexpected error: %v", name, err)
+		}
+		if !test.valid && err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package audio
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	texttospeech "cloud.google.com/go/texttospeech/apiv1"
+	"cloud.google.com/go/texttospeech/apiv1/texttospeechpb"
+	"github.com/dpetersen/language-learning/usage"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	audioEncoding = texttospeechpb.AudioEncoding_MP3
+	maxAttempts   = 3
+)
+
+// synthesizer turns SSML chunks into audio, going to the cache first and to
+// Google for anything that isn't there. It's safe to use from several
+// goroutines at once.
+type synthesizer struct {
+	cache     *Cache
+	limiter   *rateLimiter
+	voiceName string
+
+	// Only connect to Google once we know something isn't cached, so a fully
+	// cached story doesn't even need credentials.
+	connect   sync.Once
+	client    *texttospeech.Client
+	clientErr error
+}
+
+// synthesize returns the audio for chunk and whether it came from the cache.
+func (s *synthesizer) synthesize(ctx context.Context, chunk string) ([]byte, bool, error) {
+	key := cacheKey(chunk, s.voiceName, speakingRate, audioEncoding.String())
+	audio, err := s.cache.Get(key)
+	if err != nil {
+		return nil, false, err
+	}
+	if audio != nil {
+		return audio, true, nil
+	}
+
+	client, err := s.getClient(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+
+	request := &texttospeechpb.SynthesizeSpeechRequest{
+		Input: &texttospeechpb.SynthesisInput{
+			InputSource: &texttospeechpb.SynthesisInput_Ssml{Ssml: chunk},
+		},
+		Voice: &texttospeechpb.VoiceSelectionParams{
+			Name:         s.voiceName,
+			LanguageCode: "es-US",
+		},
+		AudioConfig: &texttospeechpb.AudioConfig{
+			SpeakingRate:  speakingRate,
+			AudioEncoding: audioEncoding,
+		},
+	}
+
+	characters := utf8.RuneCountInString(chunk)
+	reservation, err := usage.Allow(usage.SpeechCost(s.voiceName, characters))
+	if err != nil {
+		return nil, false, err
+	}
+	defer reservation.Release()
+
+	for attempt := 1; ; attempt++ {
+		if err := s.limiter.wait(ctx); err != nil {
+			return nil, false, err
+		}
+
+		resp, err := client.SynthesizeSpeech(ctx, request)
+		if err == nil {
+			audio = resp.AudioContent
+			reservation.RecordSpeech(s.voiceName, characters)
+			break
+		}
+
+		// Running into the quota or a flaky connection is worth another try,
+		// but anything else means the request itself is bad.
+		code := status.Code(err)
+		if attempt == maxAttempts || (code != codes.ResourceExhausted && code != codes.Unavailable) {
+			return nil, false, err
+		}
+
+		backoff := time.Duration(attempt) * 5 * time.Second
+		logrus.WithError(err).WithField("backoff", backoff).Warn("Retrying speech synthesis")
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return nil, false, ctx.Err()
+		}
+	}
+
+	if err := s.cache.Put(key, audio); err != nil {
+		return nil, false, err
+	}
+
+	return audio, false, nil
+}
+
+func (s *synthesizer) getClient(ctx context.Context) (*texttospeech.Client, error) {
+	s.connect.Do(func() {
+		s.client, s.clientErr = texttospeech.NewClient(ctx)
+		if s.clientErr != nil {
+			s.clientErr = fmt.Errorf("creating Text-to-Speech client: %v", s.clientErr)
+		}
+	})
+	return s.client, s.clientErr
+}
+
+func (s *synthesizer) close() {
+	if s.client != nil {
+		s.client.Close()
+	}
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"flag"
+
+	"github.com/dpetersen/language-learning/audio"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// AudioCache handles the audio-cache command:
+//
+//	audio-cache size
+//	audio-cache prune [-older-than 720h]
+func AudioCache(args []string) {
+	cache := audio.NewCache(viper.GetString("audio.cache_path"))
+	if len(args) == 0 {
+		logrus.Fatal("Usage: audio-cache size|prune")
+	}
+
+	switch args[0] {
+	case "size":
+		count, size, err := cache.Size()
+		if err != nil {
+			logrus.WithError(err).Fatal("Measuring audio cache")
+		}
+		logrus.WithFields(logrus.Fields{"entries": count, "bytes": size}).Info("Audio cache size")
+	case "prune":
+		flags := flag.NewFlagSet("audio-cache prune", flag.ExitOnError)
+		olderThan := flags.Duration("older-than", 0, "only remove entries unused for this long")
+		flags.Parse(args[1:])
+
+		count, size, err := cache.Prune(*olderThan)
+		if err != nil {
+			logrus.WithError(err).Fatal("Pruning audio cache")
+		}
+		logrus.WithFields(logrus.Fields{"entries": count, "bytes": size}).Info("Pruned audio cache")
+	default:
+		logrus.WithField("command", args[0]).Fatal("Unknown audio-cache command")
+	}
+}
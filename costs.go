@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/dpetersen/language-learning/usage"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// Costs handles the costs command, which prints what we've spent each day
+// and each month according to the ledger.
+func Costs(args []string) {
+	if len(args) != 0 {
+		logrus.Fatal("Usage: costs")
+	}
+
+	runs, err := usage.NewLedger(viper.GetString("costs.ledger_path")).Load()
+	if err != nil {
+		logrus.WithError(err).Fatal("Loading ledger")
+	}
+
+	fmt.Println("Daily:")
+	printTotals(usage.DailyTotals(runs))
+	fmt.Println("\nMonthly:")
+	printTotals(usage.MonthlyTotals(runs))
+}
+
+func printTotals(totals map[string]float64) {
+	periods := make([]string, 0, len(totals))
+	for period := range totals {
+		periods = append(periods, period)
+	}
+	sort.Strings(periods)
+
+	for _, period := range periods {
+		fmt.Printf("  %-10s  $%.2f\n", period, totals[period])
+	}
+}
+
+// SaveUsage adds what this run used to the ledger. It runs on the way out
+// even when the run fails, since we paid for whatever it got through.
+func SaveUsage(command string) {
+	run, err := usage.NewLedger(viper.GetString("costs.ledger_path")).Append(command)
+	if err != nil {
+		logrus.WithError(err).Error("Saving usage to ledger")
+		return
+	}
+	if run != nil {
+		logrus.WithFields(logrus.Fields{
+			"calls": len(run.Usage),
+			"cost":  fmt.Sprintf("$%.4f", run.Cost),
+		}).Info("Recorded usage")
+	}
+}
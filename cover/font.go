@@ -0,0 +1,72 @@
+package cover
+
+// A tiny 5x7 bitmap font, which is plenty for a title in big blocky letters
+// and saves us from shipping a font file and a rasterizer. Everything is
+// drawn in upper case. Accents are drawn separately above the letter, so a
+// glyph cell is really 5x9 with the top two rows reserved for those.
+const (
+	glyphWidth  = 5
+	glyphHeight = 7
+	markHeight  = 2
+)
+
+var glyphs = map[rune][glyphHeight]string{
+	'A':  {".XXX.", "X...X", "X...X", "XXXXX", "X...X", "X...X", "X...X"},
+	'B':  {"XXXX.", "X...X", "X...X", "XXXX.", "X...X", "X...X", "XXXX."},
+	'C':  {".XXX.", "X...X", "X....", "X....", "X....", "X...X", ".XXX."},
+	'D':  {"XXXX.", "X...X", "X...X", "X...X", "X...X", "X...X", "XXXX."},
+	'E':  {"XXXXX", "X....", "X....", "XXXX.", "X....", "X....", "XXXXX"},
+	'F':  {"XXXXX", "X....", "X....", "XXXX.", "X....", "X....", "X...."},
+	'G':  {".XXX.", "X...X", "X....", "X.XXX", "X...X", "X...X", ".XXXX"},
+	'H':  {"X...X", "X...X", "X...X", "XXXXX", "X...X", "X...X", "X...X"},
+	'I':  {".XXX.", "..X..", "..X..", "..X..", "..X..", "..X..", ".XXX."},
+	'J':  {"..XXX", "...X.", "...X.", "...X.", "...X.", "X..X.", ".XX.."},
+	'K':  {"X...X", "X..X.", "X.X..", "XX...", "X.X..", "X..X.", "X...X"},
+	'L':  {"X....", "X....", "X....", "X....", "X....", "X....", "XXXXX"},
+	'M':  {"X...X", "XX.XX", "X.X.X", "X.X.X", "X...X", "X...X", "X...X"},
+	'N':  {"X...X", "X...X", "XX..X", "X.X.X", "X..XX", "X...X", "X...X"},
+	'O':  {".XXX.", "X...X", "X...X", "X...X", "X...X", "X...X", ".XXX."},
+	'P':  {"XXXX.", "X...X", "X...X", "XXXX.", "X....", "X....", "X...."},
+	'Q':  {".XXX.", "X...X", "X...X", "X...X", "X.X.X", "X..X.", ".XX.X"},
+	'R':  {"XXXX.", "X...X", "X...X", "XXXX.", "X.X..", "X..X.", "X...X"},
+	'S':  {".XXXX", "X....", "X....", ".XXX.", "....X", "....X", "XXXX."},
+	'T':  {"XXXXX", "..X..", "..X..", "..X..", "..X..", "..X..", "..X.."},
+	'U':  {"X...X", "X...X", "X...X", "X...X", "X...X", "X...X", ".XXX."},
+	'V':  {"X...X", "X...X", "X...X", "X...X", "X...X", ".X.X.", "..X.."},
+	'W':  {"X...X", "X...X", "X...X", "X.X.X", "X.X.X", "X.X.X", ".X.X."},
+	'X':  {"X...X", "X...X", ".X.X.", "..X..", ".X.X.", "X...X", "X...X"},
+	'Y':  {"X...X", "X...X", ".X.X.", "..X..", "..X..", "..X..", "..X.."},
+	'Z':  {"XXXXX", "....X", "...X.", "..X..", ".X...", "X....", "XXXXX"},
+	'0':  {".XXX.", "X...X", "X..XX", "X.X.X", "XX..X", "X...X", ".XXX."},
+	'1':  {"..X..", ".XX..", "..X..", "..X..", "..X..", "..X..", ".XXX."},
+	'2':  {".XXX.", "X...X", "....X", "...X.", "..X..", ".X...", "XXXXX"},
+	'3':  {"XXXXX", "...X.", "..X..", "...X.", "....X", "X...X", ".XXX."},
+	'4':  {"...X.", "..XX.", ".X.X.", "X..X.", "XXXXX", "...X.", "...X."},
+	'5':  {"XXXXX", "X....", "XXXX.", "....X", "....X", "X...X", ".XXX."},
+	'6':  {"..XX.", ".X...", "X....", "XXXX.", "X...X", "X...X", ".XXX."},
+	'7':  {"XXXXX", "....X", "...X.", "..X..", ".X...", ".X...", ".X..."},
+	'8':  {".XXX.", "X...X", "X...X", ".XXX.", "X...X", "X...X", ".XXX."},
+	'9':  {".XXX.", "X...X", "X...X", ".XXXX", "....X", "...X.", ".XX.."},
+	' ':  {".....", ".....", ".....", ".....", ".....", ".....", "....."},
+	'.':  {".....", ".....", ".....", ".....", ".....", "..XX.", "..XX."},
+	',':  {".....", ".....", ".....", ".....", "..XX.", "...X.", "..X.."},
+	':':  {".....", "..XX.", "..XX.", ".....", "..XX.", "..XX.", "....."},
+	';':  {".....", "..XX.", "..XX.", ".....", "..XX.", "...X.", "..X.."},
+	'!':  {"..X..", "..X..", "..X..", "..X..", "..X..", ".....", "..X.."},
+	'¡':  {"..X..", ".....", "..X..", "..X..", "..X..", "..X..", "..X.."},
+	'?':  {".XXX.", "X...X", "....X", "...X.", "..X..", ".....", "..X.."},
+	'¿':  {"..X..", ".....", "..X..", ".X...", "X....", "X...X", ".XXX."},
+	'-':  {".....", ".....", ".....", "XXXXX", ".....", ".....", "....."},
+	'\'': {"..X..", "..X..", ".X...", ".....", ".....", ".....", "....."},
+	'"':  {".X.X.", ".X.X.", ".....", ".....", ".....", ".....", "....."},
+	'(':  {"...X.", "..X..", ".X...", ".X...", ".X...", "..X..", "...X."},
+	')':  {".X...", "..X..", "...X.", "...X.", "...X.", "..X..", ".X..."},
+}
+
+// marks are the combining accents that show up in Spanish once text has been
+// decomposed, drawn in the two rows above a glyph.
+var marks = map[rune][markHeight]string{
+	'\u0301': {"...X.", "..X.."}, // acute
+	'\u0303': {".XX.X", "X..X."}, // tilde
+	'\u0308': {".....", ".X.X."}, // diaeresis
+}
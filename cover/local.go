@@ -0,0 +1,264 @@
+package cover
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"strings"
+	"unicode"
+
+	"github.com/dpetersen/language-learning/gpt"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	"golang.org/x/text/unicode/norm"
+)
+
+const (
+	coverSize = 1024
+	margin    = 96
+)
+
+type palette struct {
+	top    color.RGBA
+	bottom color.RGBA
+	text   color.RGBA
+	accent color.RGBA
+}
+
+// Each style gets one of these based on a hash of its name, so the same style
+// always looks the same and different styles usually look different.
+var palettes = []palette{
+	{top: rgb(0x1b, 0x26, 0x3b), bottom: rgb(0x0d, 0x1b, 0x2a), text: rgb(0xe0, 0xe1, 0xdd), accent: rgb(0xc9, 0xa2, 0x27)},
+	{top: rgb(0x6a, 0x04, 0x0f), bottom: rgb(0x37, 0x06, 0x17), text: rgb(0xff, 0xe8, 0xd6), accent: rgb(0xf4, 0x8c, 0x06)},
+	{top: rgb(0x2d, 0x6a, 0x4f), bottom: rgb(0x08, 0x1c, 0x15), text: rgb(0xd8, 0xf3, 0xdc), accent: rgb(0xb7, 0xe4, 0xc7)},
+	{top: rgb(0xe9, 0xc4, 0x6a), bottom: rgb(0xe7, 0x6f, 0x51), text: rgb(0x26, 0x46, 0x53), accent: rgb(0x26, 0x46, 0x53)},
+	{top: rgb(0x3c, 0x09, 0x6c), bottom: rgb(0x10, 0x00, 0x2b), text: rgb(0xf0, 0xe6, 0xef), accent: rgb(0xe0, 0xaa, 0xff)},
+	{top: rgb(0xed, 0xe0, 0xd4), bottom: rgb(0xb0, 0x89, 0x68), text: rgb(0x3a, 0x2c, 0x1f), accent: rgb(0x7f, 0x55, 0x39)},
+}
+
+func rgb(r, g, b uint8) color.RGBA {
+	return color.RGBA{R: r, G: g, B: b, A: 0xff}
+}
+
+// LocalProvider draws a simple typographic cover without calling out to
+// anything, for when the image API is down, we're offline, or we'd rather
+// not pay for an image.
+type LocalProvider struct{}
+
+func NewLocalProvider() *LocalProvider {
+	return &LocalProvider{}
+}
+
+func (p *LocalProvider) Name() string {
+	return "local"
+}
+
+func (p *LocalProvider) CreateImage(story gpt.Story) (*Image, error) {
+	colors := paletteForStyle(story.Style)
+	img := image.NewRGBA(image.Rect(0, 0, coverSize, coverSize))
+
+	for y := 0; y < coverSize; y++ {
+		row := blend(colors.top, colors.bottom, float64(y)/coverSize)
+		draw.Draw(img, image.Rect(0, y, coverSize, y+1), image.NewUniform(row), image.Point{}, draw.Src)
+	}
+
+	// A thin frame inset from the edges, like an old paperback.
+	frame := image.NewUniform(colors.accent)
+	for _, r := range []image.Rectangle{
+		image.Rect(margin/2, margin/2, coverSize-margin/2, margin/2+6),
+		image.Rect(margin/2, coverSize-margin/2-6, coverSize-margin/2, coverSize-margin/2),
+		image.Rect(margin/2, margin/2, margin/2+6, coverSize-margin/2),
+		image.Rect(coverSize-margin/2-6, margin/2, coverSize-margin/2, coverSize-margin/2),
+	} {
+		draw.Draw(img, r, frame, image.Point{}, draw.Src)
+	}
+
+	if series := viper.GetString("series.name"); series != "" {
+		lines, scale, ok := fitText(series, titleWidth, titleTop-(margin+20)-10, 4, 2)
+		if !ok {
+			logrus.WithField("series", series).Warn("Series name doesn't fit on the cover, cutting it short")
+		}
+		drawLines(img, lines, margin+20, scale, colors.accent)
+	}
+	if chapter := viper.GetInt("series.chapter"); chapter > 0 {
+		drawCentered(img, fmt.Sprintf("Capítulo %d", chapter), coverSize-margin-20-lineHeight(5), 5, colors.accent)
+	}
+	drawTitle(img, story.Title, colors.text)
+
+	var encoded bytes.Buffer
+	if err := png.Encode(&encoded, img); err != nil {
+		return nil, fmt.Errorf("encoding PNG: %v", err)
+	}
+
+	return &Image{Data: base64.StdEncoding.EncodeToString(encoded.Bytes())}, nil
+}
+
+// Illustrate always fails, since a title card is no illustration. Scenes
+// without an image are simply left out.
+func (p *LocalProvider) Illustrate(story gpt.Story, scene gpt.Scene) (*Image, error) {
+	return nil, errors.New("the local provider can't illustrate scenes")
+}
+
+func paletteForStyle(style string) palette {
+	hash := fnv.New32a()
+	hash.Write([]byte(style))
+	return palettes[hash.Sum32()%uint32(len(palettes))]
+}
+
+func blend(a, b color.RGBA, t float64) color.RGBA {
+	mix := func(x, y uint8) uint8 {
+		return uint8(float64(x) + (float64(y)-float64(x))*t)
+	}
+	return color.RGBA{R: mix(a.R, b.R), G: mix(a.G, b.G), B: mix(a.B, b.B), A: 0xff}
+}
+
+// The title goes in a box in the middle of the cover, and the series name
+// fits in above it.
+const (
+	titleWidth  = coverSize - 3*margin
+	titleHeight = coverSize - 5*margin
+	titleTop    = (coverSize - titleHeight) / 2
+)
+
+// drawTitle wraps the title and draws it as large as will fit in the middle of
+// the cover.
+func drawTitle(img *image.RGBA, title string, c color.RGBA) {
+	lines, scale, ok := fitText(title, titleWidth, titleHeight, 16, 2)
+	if !ok {
+		logrus.WithField("title", title).Warn("Title doesn't fit on the cover, cutting it short")
+	}
+	drawLines(img, lines, (coverSize-len(lines)*lineHeight(scale))/2, scale, c)
+}
+
+func drawLines(img *image.RGBA, lines []string, y, scale int, c color.RGBA) {
+	for _, line := range lines {
+		drawCentered(img, line, y, scale, c)
+		y += lineHeight(scale)
+	}
+}
+
+// fitText wraps text to fit in a box width by height pixels, at the largest
+// scale from largest down to smallest that fits without breaking any words.
+// If none does, it's wrapped at smallest with long words broken across lines,
+// whatever still doesn't fit is cut off, and ok is false.
+func fitText(text string, width, height, largest, smallest int) (lines []string, scale int, ok bool) {
+	for scale := largest; scale >= smallest; scale-- {
+		lines := wrap(text, width/charWidth(scale))
+		if lines != nil && len(lines)*lineHeight(scale) <= height {
+			return lines, scale, true
+		}
+	}
+
+	columns := width / charWidth(smallest)
+	lines = wrap(breakWords(text, columns), columns)
+	if rows := max(1, height/lineHeight(smallest)); len(lines) > rows {
+		lines = lines[:rows]
+		last := []rune(lines[rows-1])
+		lines[rows-1] = string(last[:min(len(last), columns-3)]) + "..."
+	}
+	return lines, smallest, false
+}
+
+// breakWords splits any word longer than width characters into pieces that
+// fit, each but the last ending in a hyphen.
+func breakWords(text string, width int) string {
+	var words []string
+	for _, word := range strings.Fields(text) {
+		runes := []rune(word)
+		for len(runes) > width && width > 1 {
+			words = append(words, string(runes[:width-1])+"-")
+			runes = runes[width-1:]
+		}
+		words = append(words, string(runes))
+	}
+	return strings.Join(words, " ")
+}
+
+// wrap breaks text into lines of at most width characters, or returns nil if
+// there's a word that's too long to fit at all.
+func wrap(text string, width int) []string {
+	var lines []string
+	var line []rune
+	for _, word := range strings.Fields(text) {
+		runes := []rune(word)
+		if len(runes) > width {
+			return nil
+		}
+		if len(line) > 0 && len(line)+1+len(runes) > width {
+			lines = append(lines, string(line))
+			line = nil
+		}
+		if len(line) > 0 {
+			line = append(line, ' ')
+		}
+		line = append(line, runes...)
+	}
+	if len(line) > 0 {
+		lines = append(lines, string(line))
+	}
+	return lines
+}
+
+func charWidth(scale int) int {
+	return (glyphWidth + 1) * scale
+}
+
+func lineHeight(scale int) int {
+	return (markHeight + glyphHeight + 2) * scale
+}
+
+// drawCentered draws a single line of text centered horizontally, with the
+// top of its accent row at y.
+func drawCentered(img *image.RGBA, text string, y, scale int, c color.RGBA) {
+	letters := layout(text)
+	x := (coverSize - len(letters)*charWidth(scale)) / 2
+	for _, letter := range letters {
+		if glyph, ok := glyphs[letter.base]; ok {
+			drawBitmap(img, glyph[:], x, y+markHeight*scale, scale, c)
+		}
+		if mark, ok := marks[letter.mark]; ok {
+			drawBitmap(img, mark[:], x, y, scale, c)
+		}
+		x += charWidth(scale)
+	}
+}
+
+type letter struct {
+	base rune
+	mark rune
+}
+
+// layout upper cases text and splits each character into its base letter and
+// accent, so that "canción" is drawn as CANCION with an acute over the O.
+func layout(text string) []letter {
+	var letters []letter
+	for _, r := range norm.NFD.String(strings.ToUpper(text)) {
+		if unicode.Is(unicode.Mn, r) {
+			if len(letters) > 0 {
+				letters[len(letters)-1].mark = r
+			}
+			continue
+		}
+		letters = append(letters, letter{base: r})
+	}
+	return letters
+}
+
+func drawBitmap(img *image.RGBA, rows []string, x, y, scale int, c color.RGBA) {
+	fill := image.NewUniform(c)
+	for row, pixels := range rows {
+		for col, pixel := range pixels {
+			if pixel != 'X' {
+				continue
+			}
+			r := image.Rect(x+col*scale, y+row*scale, x+(col+1)*scale, y+(row+1)*scale)
+			draw.Draw(img, r, fill, image.Point{}, draw.Src)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package cover
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFitTextBreaksLongWords(t *testing.T) {
+	title := "El " + strings.Repeat("Extraordinario", 10) + " Viaje"
+	lines, scale, ok := fitText(title, titleWidth, titleHeight, 16, 2)
+	if ok {
+		t.Error("expected the title not to fit without breaking words")
+	}
+	if scale != 2 {
+		t.Errorf("scale = %d, want the smallest, 2", scale)
+	}
+	if len(lines) < 2 {
+		t.Fatalf("lines = %q, want the long word broken across lines", lines)
+	}
+	for _, line := range lines {
+		if width := len([]rune(line)) * charWidth(scale); width > titleWidth {
+			t.Errorf("line %q is %d pixels wide, over %d", line, width, titleWidth)
+		}
+	}
+}
+
+func TestFitTextCutsOffWhatDoesntFit(t *testing.T) {
+	lines, scale, ok := fitText(strings.Repeat("palabra ", 200), titleWidth, lineHeight(2)*3, 4, 2)
+	if ok {
+		t.Error("expected the text not to fit")
+	}
+	if len(lines)*lineHeight(scale) > lineHeight(2)*3 {
+		t.Errorf("%d lines at scale %d don't fit", len(lines), scale)
+	}
+	if last := lines[len(lines)-1]; !strings.HasSuffix(last, "...") {
+		t.Errorf("last line %q doesn't show it was cut off", last)
+	}
+}
+
+func TestFitTextPrefersLargestScale(t *testing.T) {
+	lines, scale, ok := fitText("La casa", titleWidth, titleHeight, 16, 2)
+	if !ok || scale != 16 || len(lines) != 1 {
+		t.Errorf("fitText() = %q at %d (%v), want one line at 16", lines, scale, ok)
+	}
+}
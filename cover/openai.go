@@ -0,0 +1,34 @@
+package cover
+
+import "github.com/dpetersen/language-learning/gpt"
+
+// OpenAIProvider generates the cover with DALL-E.
+type OpenAIProvider struct {
+	client *gpt.Client
+}
+
+func NewOpenAIProvider(client *gpt.Client) *OpenAIProvider {
+	return &OpenAIProvider{client: client}
+}
+
+func (p *OpenAIProvider) Name() string {
+	return "openai"
+}
+
+func (p *OpenAIProvider) CreateImage(story gpt.Story) (*Image, error) {
+	image, err := p.client.CreateImage(story)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Image{Data: image.B64JSON, Prompt: image.RevisedPrompt}, nil
+}
+
+func (p *OpenAIProvider) Illustrate(story gpt.Story, scene gpt.Scene) (*Image, error) {
+	image, err := p.client.CreateSceneImage(story, scene)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Image{Data: image.B64JSON, Prompt: image.RevisedPrompt}, nil
+}
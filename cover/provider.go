@@ -0,0 +1,79 @@
+package cover
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/dpetersen/language-learning/gpt"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// Provider creates a cover image for a story, and can illustrate individual
+// scenes from it.
+type Provider interface {
+	Name() string
+	CreateImage(story gpt.Story) (*Image, error)
+	Illustrate(story gpt.Story, scene gpt.Scene) (*Image, error)
+}
+
+// Image is a base64-encoded PNG, plus the prompt that produced it for
+// providers that have one.
+type Image struct {
+	Data   string
+	Prompt string
+}
+
+// ProvidersFromConfig returns the providers listed in image.providers, in the
+// order they should be tried.
+func ProvidersFromConfig(client *gpt.Client) ([]Provider, error) {
+	var providers []Provider
+	for _, name := range viper.GetStringSlice("image.providers") {
+		switch name {
+		case "openai":
+			providers = append(providers, NewOpenAIProvider(client))
+		case "local":
+			providers = append(providers, NewLocalProvider())
+		default:
+			return nil, fmt.Errorf("unknown image provider %q", name)
+		}
+	}
+
+	if len(providers) == 0 {
+		return nil, errors.New("no image providers configured")
+	}
+
+	return providers, nil
+}
+
+// Create tries each provider in turn, falling back to the next one whenever
+// one fails. That way a flaky or unreachable image API doesn't sink the whole
+// run as long as there's something to fall back on.
+func Create(providers []Provider, story gpt.Story) (*Image, error) {
+	return firstSuccessful(providers, func(provider Provider) (*Image, error) {
+		return provider.CreateImage(story)
+	})
+}
+
+// Illustrate is like Create, but for a single scene of the story.
+func Illustrate(providers []Provider, story gpt.Story, scene gpt.Scene) (*Image, error) {
+	return firstSuccessful(providers, func(provider Provider) (*Image, error) {
+		return provider.Illustrate(story, scene)
+	})
+}
+
+func firstSuccessful(providers []Provider, create func(Provider) (*Image, error)) (*Image, error) {
+	var errs []error
+	for _, provider := range providers {
+		image, err := create(provider)
+		if err == nil {
+			logrus.WithField("provider", provider.Name()).Debug("Created image")
+			return image, nil
+		}
+
+		logrus.WithError(err).WithField("provider", provider.Name()).Warn("Image provider failed")
+		errs = append(errs, fmt.Errorf("%s: %v", provider.Name(), err))
+	}
+
+	return nil, fmt.Errorf("all image providers failed: %v", errors.Join(errs...))
+}
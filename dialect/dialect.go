@@ -0,0 +1,200 @@
+// Package dialect checks text for words and forms that belong to a different
+// region than the one we're learning, like Spain's "vosotros" in a story that
+// should be in Mexican Spanish.
+package dialect
+
+import (
+	"bufio"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/dpetersen/language-learning/lemma"
+)
+
+//go:embed profiles/*.txt
+var profiles embed.FS
+
+const (
+	kindWord         = "word"
+	kindLemma        = "lemma"
+	kindEnding       = "ending"
+	kindInterjection = "interjection"
+)
+
+// Profile is the set of rules for one region of one language.
+type Profile struct {
+	words   map[string]string
+	lemmas  map[string]string
+	endings []ending
+	// interjections only match a word standing on its own at the start of a
+	// sentence, like "¡Vale!" or "Vale, vamos".
+	interjections map[string]string
+	// lemmaSet matches every form of the words in lemmas.
+	lemmaSet *lemma.Set
+}
+
+type ending struct {
+	suffix string
+	prefer string
+}
+
+// Problem is a word that doesn't belong, how often it came up, and what to
+// use instead.
+type Problem struct {
+	Word   string
+	Count  int
+	Prefer string
+}
+
+func (p Problem) String() string {
+	return fmt.Sprintf("replace %q with %s", p.Word, p.Prefer)
+}
+
+// Regions returns the regions there are profiles for in language.
+func Regions(language string) []string {
+	entries, _ := profiles.ReadDir("profiles")
+
+	var regions []string
+	for _, entry := range entries {
+		name := strings.TrimSuffix(entry.Name(), path.Ext(entry.Name()))
+		if region, ok := strings.CutPrefix(name, language+"-"); ok {
+			regions = append(regions, region)
+		}
+	}
+	return regions
+}
+
+// Load returns the profile for region of language, such as "mx" for "es".
+func Load(language, region string) (*Profile, error) {
+	file, err := profiles.Open("profiles/" + language + "-" + region + ".txt")
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("no dialect profile for %q in %q, have %v", region, language, Regions(language))
+		}
+		return nil, fmt.Errorf("opening dialect profile: %v", err)
+	}
+	defer file.Close()
+
+	profile := &Profile{
+		words:         make(map[string]string),
+		lemmas:        make(map[string]string),
+		interjections: make(map[string]string),
+	}
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		rule, prefer, ok := strings.Cut(text, "=>")
+		kind, pattern, _ := strings.Cut(strings.TrimSpace(rule), " ")
+		pattern = strings.ToLower(strings.TrimSpace(pattern))
+		prefer = strings.TrimSpace(prefer)
+		if !ok || pattern == "" || prefer == "" {
+			return nil, fmt.Errorf("dialect profile line %d isn't a rule: %q", line, text)
+		}
+
+		switch kind {
+		case kindWord:
+			profile.words[pattern] = prefer
+		case kindLemma:
+			profile.lemmas[pattern] = prefer
+		case kindEnding:
+			profile.endings = append(profile.endings, ending{suffix: pattern, prefer: prefer})
+		case kindInterjection:
+			profile.interjections[pattern] = prefer
+		default:
+			return nil, fmt.Errorf("dialect profile line %d has unknown kind %q", line, kind)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading dialect profile: %v", err)
+	}
+
+	lemmas := make([]string, 0, len(profile.lemmas))
+	for word := range profile.lemmas {
+		lemmas = append(lemmas, word)
+	}
+	profile.lemmaSet = lemma.NewSet(language, lemmas)
+
+	return profile, nil
+}
+
+// Lint returns every word in text that the profile has a rule against, in the
+// order they first come up.
+func (p *Profile) Lint(text string) []Problem {
+	var problems []Problem
+	found := make(map[string]int)
+	text = strings.ToLower(text)
+	for start := 0; start < len(text); {
+		offset := strings.IndexFunc(text[start:], unicode.IsLetter)
+		if offset < 0 {
+			break
+		}
+		start += offset
+		end := len(text)
+		if offset := strings.IndexFunc(text[start:], notLetter); offset >= 0 {
+			end = start + offset
+		}
+		word := text[start:end]
+
+		prefer, ok := p.match(word)
+		if !ok {
+			prefer, ok = p.interjections[word]
+			ok = ok && interjection(text[:start], text[end:])
+		}
+		start = end
+		if !ok {
+			continue
+		}
+
+		if i, ok := found[word]; ok {
+			problems[i].Count++
+			continue
+		}
+		found[word] = len(problems)
+		problems = append(problems, Problem{Word: word, Count: 1, Prefer: prefer})
+	}
+	return problems
+}
+
+func notLetter(r rune) bool {
+	return !unicode.IsLetter(r)
+}
+
+// interjection reports whether a word between before and after stands on its
+// own at the start of a sentence: the sentence is just the word, as in
+// "Vale." or "¡Vale!", or the word is set off with a comma, as in "Vale,
+// vamos". Quotes, dashes and opening marks in front of it don't count.
+func interjection(before, after string) bool {
+	before = strings.TrimRight(before, " \t\"'«“‘—–-¡¿")
+	if last, _ := utf8.DecodeLastRuneInString(before); before != "" && !strings.ContainsRune(".!?…\n", last) {
+		return false
+	}
+	next, _ := utf8.DecodeRuneInString(strings.TrimLeft(after, " \t"))
+	return strings.ContainsRune(".,!?…", next)
+}
+
+func (p *Profile) match(word string) (string, bool) {
+	if prefer, ok := p.words[word]; ok {
+		return prefer, true
+	}
+	if prefer, ok := p.lemmas[p.lemmaSet.Lemma(word)]; ok {
+		return prefer, true
+	}
+	for _, ending := range p.endings {
+		// The ending alone isn't a word, and a couple of letters in front of
+		// it keeps short words out of it.
+		if strings.HasSuffix(word, ending.suffix) && len([]rune(word)) >= len([]rune(ending.suffix))+2 {
+			return ending.prefer, true
+		}
+	}
+	return "", false
+}
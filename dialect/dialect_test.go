@@ -0,0 +1,32 @@
+package dialect
+
+import "testing"
+
+func TestLintInterjection(t *testing.T) {
+	profile, err := Load("es", "mx")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]int{
+		"Vale.":                                   1,
+		"—¡Vale! Nos vemos mañana.":               1,
+		"Lo pensó un momento. Vale, vamos.":       1,
+		"¿Vale? Vale.":                            2,
+		"¿Cuánto vale?":                           0,
+		"No vale la pena.":                        0,
+		"Vale la pena intentarlo.":                0,
+		"El libro vale, más o menos, diez pesos.": 0,
+	}
+	for text, want := range tests {
+		var got int
+		for _, problem := range profile.Lint(text) {
+			if problem.Word == "vale" {
+				got = problem.Count
+			}
+		}
+		if got != want {
+			t.Errorf("Lint(%q) flagged \"vale\" %d times, want %d", text, got, want)
+		}
+	}
+}
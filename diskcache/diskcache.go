@@ -0,0 +1,176 @@
+// Package diskcache stores entries on disk under a hash of whatever went into
+// making them, so anything expensive to produce only has to be produced once.
+// The audio and OpenAI response caches are both built on it.
+package diskcache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+type Cache struct {
+	path string
+	// extension is added to every entry's file name, like ".json".
+	extension string
+}
+
+func New(path, extension string) *Cache {
+	return &Cache{path: path, extension: extension}
+}
+
+// Key hashes parts into a key, so any change to any of them is a different
+// entry.
+func Key(parts ...string) string {
+	hash := sha256.New()
+	hash.Write([]byte(strings.Join(parts, "\x00")))
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+func (c *Cache) entryPath(key string) string {
+	// Split on the first couple of characters so no single directory ends up
+	// with thousands of files in it.
+	return filepath.Join(c.path, key[:2], key+c.extension)
+}
+
+// Get returns the entry for key, or nil if there isn't one or it was last
+// written or touched more than ttl ago. A ttl of zero never expires anything.
+func (c *Cache) Get(key string, ttl time.Duration) ([]byte, error) {
+	path := c.entryPath(key)
+	info, err := os.Stat(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("reading cache entry: %v", err)
+	}
+	if ttl > 0 && time.Since(info.ModTime()) > ttl {
+		return nil, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("reading cache entry: %v", err)
+	}
+
+	return data, nil
+}
+
+// Touch bumps the modification time of the entry for key, so pruning by age
+// leaves it alone.
+func (c *Cache) Touch(key string) error {
+	now := time.Now()
+	if err := os.Chtimes(c.entryPath(key), now, now); err != nil {
+		return fmt.Errorf("touching cache entry: %v", err)
+	}
+	return nil
+}
+
+func (c *Cache) Put(key string, data []byte) error {
+	path := c.entryPath(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("creating cache directory: %v", err)
+	}
+
+	// Write somewhere else first so an interrupted run can't leave a truncated
+	// file behind that we'd happily serve up later. Each write gets its own
+	// temporary file, since two workers can be putting the same entry at once.
+	tmp, err := os.CreateTemp(filepath.Dir(path), key+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("creating cache entry: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("writing cache entry: %v", err)
+	}
+	if err := tmp.Chmod(0o644); err != nil {
+		tmp.Close()
+		return fmt.Errorf("writing cache entry: %v", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("writing cache entry: %v", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("moving cache entry into place: %v", err)
+	}
+
+	return nil
+}
+
+// Size returns how many entries are in the cache and how many bytes they take
+// up.
+func (c *Cache) Size() (int, int64, error) {
+	var count int
+	var total int64
+	err := c.walk(func(_ string, info fs.FileInfo) error {
+		count++
+		total += info.Size()
+		return nil
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return count, total, nil
+}
+
+// Prune removes every entry that hasn't been written or touched in olderThan,
+// returning how many were removed and how many bytes that freed. Zero removes
+// everything.
+func (c *Cache) Prune(olderThan time.Duration) (int, int64, error) {
+	cutoff := time.Now().Add(-olderThan)
+
+	var count int
+	var total int64
+	err := c.walk(func(path string, info fs.FileInfo) error {
+		if olderThan > 0 && info.ModTime().After(cutoff) {
+			return nil
+		}
+		if err := os.Remove(path); err != nil {
+			return fmt.Errorf("removing cache entry: %v", err)
+		}
+		count++
+		total += info.Size()
+		return nil
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return count, total, nil
+}
+
+func (c *Cache) walk(fn func(path string, info fs.FileInfo) error) error {
+	err := filepath.WalkDir(c.path, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if entry.IsDir() {
+			return nil
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		return fn(path, info)
+	})
+	if err != nil {
+		return fmt.Errorf("walking cache directory: %v", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,95 @@
+// Package frequency provides lists of the most common words in each language
+// we support, so we can point the model at new words worth learning.
+package frequency
+
+import (
+	"bufio"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+)
+
+//go:embed lists/*.txt
+var lists embed.FS
+
+// List is a frequency list for one language. Ranks start at 1 for the most
+// common word.
+type List struct {
+	words []string
+	ranks map[string]int
+}
+
+// Languages returns the languages there are lists for.
+func Languages() []string {
+	entries, _ := lists.ReadDir("lists")
+
+	var languages []string
+	for _, entry := range entries {
+		languages = append(languages, strings.TrimSuffix(entry.Name(), ".txt"))
+	}
+	sort.Strings(languages)
+	return languages
+}
+
+// Load reads the list for language, which is an ISO 639-1 code like "es".
+func Load(language string) (*List, error) {
+	file, err := lists.Open(path.Join("lists", language+".txt"))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("no frequency list for language %q", language)
+		}
+		return nil, fmt.Errorf("opening frequency list: %v", err)
+	}
+	defer file.Close()
+
+	list := &List{ranks: make(map[string]int)}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		word := strings.ToLower(strings.TrimSpace(scanner.Text()))
+		if word == "" || strings.HasPrefix(word, "#") {
+			continue
+		}
+		if _, exists := list.ranks[word]; exists {
+			continue
+		}
+		list.words = append(list.words, word)
+		list.ranks[word] = len(list.words)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading frequency list: %v", err)
+	}
+
+	return list, nil
+}
+
+// Rank returns how common word is, where 1 is the most common, and false if
+// it isn't on the list at all.
+func (l *List) Rank(word string) (int, bool) {
+	rank, ok := l.ranks[strings.ToLower(word)]
+	return rank, ok
+}
+
+// Top returns up to n of the most common words for which skip returns false,
+// most common first. skip may be nil.
+func (l *List) Top(n int, skip func(word string) bool) []string {
+	var result []string
+	for _, word := range l.words {
+		if len(result) == n {
+			break
+		}
+		if skip != nil && skip(word) {
+			continue
+		}
+		result = append(result, word)
+	}
+	return result
+}
+
+// Len returns how many words are on the list.
+func (l *List) Len() int {
+	return len(l.words)
+}
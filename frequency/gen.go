@@ -0,0 +1,61 @@
+//go:build ignore
+
+// gen turns a word count file from FrequencyWords into one of our lists. The
+// counts are one "word count" pair per line, most frequent first, as in
+// content/2018/es/es_50k.txt from https://github.com/hermitdave/FrequencyWords:
+//
+//	go run gen.go -language Spanish es_50k.txt > lists/es.txt
+//
+// Anything that isn't a plain word, like numbers and contractions, is left
+// out.
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+)
+
+func main() {
+	language := flag.String("language", "", "name of the language, for the header")
+	n := flag.Int("n", 5000, "how many words to keep")
+	flag.Parse()
+	if flag.NArg() != 1 || *language == "" {
+		log.Fatal("usage: go run gen.go -language NAME [-n WORDS] COUNTS_FILE")
+	}
+
+	file, err := os.Open(flag.Arg(0))
+	if err != nil {
+		log.Fatalf("opening counts: %v", err)
+	}
+	defer file.Close()
+
+	out := bufio.NewWriter(os.Stdout)
+	defer out.Flush()
+	fmt.Fprintf(out, "# %s words, most frequent first, one per line.\n", *language)
+	fmt.Fprintf(out, "# Generated by gen.go from %s in FrequencyWords, counted over\n", filepath.Base(flag.Arg(0)))
+	fmt.Fprintf(out, "# OpenSubtitles 2018 and licensed CC BY-SA 4.0. See README.\n")
+
+	seen := make(map[string]bool)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() && len(seen) < *n {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 {
+			continue
+		}
+		word := strings.ToLower(fields[0])
+		if seen[word] || strings.IndexFunc(word, func(r rune) bool { return !unicode.IsLetter(r) }) >= 0 {
+			continue
+		}
+		seen[word] = true
+		fmt.Fprintln(out, word)
+	}
+	if err := scanner.Err(); err != nil {
+		log.Fatalf("reading counts: %v", err)
+	}
+}
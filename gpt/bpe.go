@@ -0,0 +1,168 @@
+package gpt
+
+import (
+	"bufio"
+	"compress/gzip"
+	"embed"
+	"encoding/base64"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+//go:embed encodings/*.tiktoken.gz
+var encodingFiles embed.FS
+
+// encoding is one of OpenAI's byte pair encodings: a pattern that splits text
+// into pieces, and the rank of every token that pieces are merged into, with
+// the lowest ranked merges made first. The ranks are only loaded the first
+// time they're needed, since there are a couple of hundred thousand of them.
+type encoding struct {
+	name    string
+	pattern *regexp.Regexp
+
+	load  sync.Once
+	ranks map[string]int
+}
+
+// These are tiktoken's patterns, with a couple of changes for Go's regexp
+// package. \s only matches ASCII in Go, so ws spells out Unicode whitespace.
+// And there's no lookahead, so `\s+(?!\S)` is left out and handled in split.
+const ws = `\t\n\v\f\r\x{85}\p{Z}`
+
+var (
+	cl100kBase = &encoding{
+		name: "cl100k_base",
+		pattern: regexp.MustCompile(`^(?:` +
+			`(?i:'s|'t|'re|'ve|'m|'ll|'d)` +
+			`|[^\r\n\pL\pN]?\pL+` +
+			`|\pN{1,3}` +
+			`| ?[^` + ws + `\pL\pN]+[\r\n]*` +
+			`|[` + ws + `]*[\r\n]+` +
+			`|[` + ws + `]+)`),
+	}
+	o200kBase = &encoding{
+		name: "o200k_base",
+		pattern: regexp.MustCompile(`^(?:` +
+			`[^\r\n\pL\pN]?[\p{Lu}\p{Lt}\p{Lm}\p{Lo}\p{M}]*[\p{Ll}\p{Lm}\p{Lo}\p{M}]+(?i:'s|'t|'re|'ve|'m|'ll|'d)?` +
+			`|[^\r\n\pL\pN]?[\p{Lu}\p{Lt}\p{Lm}\p{Lo}\p{M}]+[\p{Ll}\p{Lm}\p{Lo}\p{M}]*(?i:'s|'t|'re|'ve|'m|'ll|'d)?` +
+			`|\pN{1,3}` +
+			`| ?[^` + ws + `\pL\pN]+[\r\n/]*` +
+			`|[` + ws + `]*[\r\n]+` +
+			`|[` + ws + `]+)`),
+	}
+)
+
+// count returns how many tokens text encodes to.
+func (e *encoding) count(text string) int {
+	e.load.Do(e.loadRanks)
+
+	var tokens int
+	for _, piece := range e.split(text) {
+		tokens += e.countPiece(piece)
+	}
+	return tokens
+}
+
+// split breaks text into the pieces that are encoded separately.
+func (e *encoding) split(text string) []string {
+	var pieces []string
+	for len(text) > 0 {
+		end := len(text)
+		if loc := e.pattern.FindStringIndex(text); loc != nil && loc[1] > 0 {
+			end = loc[1]
+		} else {
+			_, end = utf8.DecodeRuneInString(text)
+		}
+
+		// This is `\s+(?!\S)`: a run of whitespace that something else follows
+		// leaves its last character to go with the next piece, like " word".
+		// Runs ending in a newline are matched before this gets a chance.
+		piece := text[:end]
+		if end < len(text) && isWhitespace(piece) && !strings.HasSuffix(piece, "\n") && !strings.HasSuffix(piece, "\r") {
+			if _, size := utf8.DecodeLastRuneInString(piece); size < len(piece) {
+				end -= size
+			}
+		}
+
+		pieces = append(pieces, text[:end])
+		text = text[end:]
+	}
+	return pieces
+}
+
+func isWhitespace(s string) bool {
+	for _, r := range s {
+		if !unicode.IsSpace(r) && !unicode.Is(unicode.Z, r) {
+			return false
+		}
+	}
+	return true
+}
+
+// countPiece merges the bytes of piece, lowest ranked pair first, until no
+// more pairs can be merged, and returns how many tokens are left.
+func (e *encoding) countPiece(piece string) int {
+	if _, ok := e.ranks[piece]; ok {
+		return 1
+	}
+
+	// boundaries[i] is where the ith part starts.
+	boundaries := make([]int, len(piece)+1)
+	for i := range boundaries {
+		boundaries[i] = i
+	}
+	for len(boundaries) > 2 {
+		best, bestRank := -1, 0
+		for i := 0; i+2 < len(boundaries); i++ {
+			rank, ok := e.ranks[piece[boundaries[i]:boundaries[i+2]]]
+			if ok && (best < 0 || rank < bestRank) {
+				best, bestRank = i, rank
+			}
+		}
+		if best < 0 {
+			break
+		}
+		boundaries = append(boundaries[:best+1], boundaries[best+2:]...)
+	}
+	return len(boundaries) - 1
+}
+
+// loadRanks reads the embedded ranks. They're part of the binary, so if they
+// can't be read nothing that counts tokens can work.
+func (e *encoding) loadRanks() {
+	file, err := encodingFiles.Open("encodings/" + e.name + ".tiktoken.gz")
+	if err != nil {
+		panic(fmt.Sprintf("opening %s: %v", e.name, err))
+	}
+	defer file.Close()
+	reader, err := gzip.NewReader(file)
+	if err != nil {
+		panic(fmt.Sprintf("decompressing %s: %v", e.name, err))
+	}
+
+	e.ranks = make(map[string]int)
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		token, rank, ok := strings.Cut(scanner.Text(), " ")
+		if !ok {
+			continue
+		}
+		decoded, err := base64.StdEncoding.DecodeString(token)
+		if err != nil {
+			panic(fmt.Sprintf("decoding %s token %q: %v", e.name, token, err))
+		}
+		n, err := strconv.Atoi(rank)
+		if err != nil {
+			panic(fmt.Sprintf("decoding %s rank %q: %v", e.name, rank, err))
+		}
+		e.ranks[string(decoded)] = n
+	}
+	if err := scanner.Err(); err != nil {
+		panic(fmt.Sprintf("reading %s: %v", e.name, err))
+	}
+}
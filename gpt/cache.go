@@ -0,0 +1,72 @@
+package gpt
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/dpetersen/language-learning/diskcache"
+)
+
+// Cache modes. In CacheOn, a response is reused for any identical request
+// until it's older than the TTL. CacheRecord always calls the API but saves
+// every response, and CacheReplay never calls it, failing on any request it
+// doesn't have a response for, so a recorded cache works as test fixtures.
+const (
+	CacheOff    = "off"
+	CacheOn     = "on"
+	CacheRecord = "record"
+	CacheReplay = "replay"
+)
+
+// ResponseCache stores API responses on disk, addressed by a hash of the
+// URL and the full request body, so it's only a hit when the model, messages,
+// temperature and everything else are exactly the same.
+type ResponseCache struct {
+	*diskcache.Cache
+}
+
+// cacheEntry keeps the request alongside the response, so the cache can be
+// read through when it's being used as fixtures.
+type cacheEntry struct {
+	URL      string          `json:"url"`
+	Request  json.RawMessage `json:"request"`
+	Response json.RawMessage `json:"response"`
+}
+
+func NewResponseCache(path string) *ResponseCache {
+	return &ResponseCache{Cache: diskcache.New(path, ".json")}
+}
+
+func responseCacheKey(url string, requestBody []byte) string {
+	return diskcache.Key(url, string(requestBody))
+}
+
+// Get returns the cached response for key, or nil if there isn't one or it's
+// older than ttl. A ttl of zero never expires anything.
+func (c *ResponseCache) Get(key string, ttl time.Duration) ([]byte, error) {
+	data, err := c.Cache.Get(key, ttl)
+	if err != nil || data == nil {
+		return nil, err
+	}
+
+	var entry cacheEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return nil, fmt.Errorf("decoding cache entry: %v", err)
+	}
+
+	return entry.Response, nil
+}
+
+func (c *ResponseCache) Put(key, url string, requestBody, responseBody []byte) error {
+	data, err := json.MarshalIndent(cacheEntry{
+		URL:      url,
+		Request:  requestBody,
+		Response: responseBody,
+	}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshalling JSON: %v", err)
+	}
+
+	return c.Cache.Put(key, data)
+}
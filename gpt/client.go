@@ -3,6 +3,7 @@ package gpt
 import (
 	"encoding/json"
 	"fmt"
+	"time"
 
 	"github.com/go-resty/resty/v2"
 	"github.com/sirupsen/logrus"
@@ -15,20 +16,42 @@ type Client struct {
 	client *resty.Client
 	model  string
 	apiKey string
+
+	cache     *ResponseCache
+	cacheMode string
+	cacheTTL  time.Duration
 }
 
 func NewClient(apiKey, model string) *Client {
 	return &Client{
-		client: resty.New().SetDebug(viper.GetBool("openai.http_debug")),
-		model:  model,
-		apiKey: apiKey,
+		client:    resty.New().SetDebug(viper.GetBool("openai.http_debug")),
+		model:     model,
+		apiKey:    apiKey,
+		cache:     NewResponseCache(viper.GetString("openai.cache.path")),
+		cacheMode: viper.GetString("openai.cache.mode"),
+		cacheTTL:  viper.GetDuration("openai.cache.ttl"),
 	}
 }
 
-func (c *Client) makeAPICall(requestObject interface{}, url string, responseObject interface{}) error {
+// makeAPICall sends the request, or answers it from the cache if that's
+// turned on, and reports which it did so callers don't count cached responses
+// as spending.
+func (c *Client) makeAPICall(requestObject interface{}, url string, responseObject interface{}) (bool, error) {
 	requestBody, err := json.Marshal(requestObject)
 	if err != nil {
-		return fmt.Errorf("serializing request to JSON: %v", err)
+		return false, fmt.Errorf("serializing request to JSON: %v", err)
+	}
+
+	key := responseCacheKey(url, requestBody)
+	cached, err := c.cachedResponse(key)
+	if err != nil {
+		return false, err
+	}
+	if cached != nil {
+		if err := json.Unmarshal(cached, responseObject); err != nil {
+			return false, fmt.Errorf("decoding cached response: %v", err)
+		}
+		return true, nil
 	}
 
 	logrus.WithField("requestBody", string(requestBody)).Debug("Sending request to OpenAI API")
@@ -39,10 +62,53 @@ func (c *Client) makeAPICall(requestObject interface{}, url string, responseObje
 		SetResult(responseObject).
 		Post(url)
 	if err != nil {
-		return fmt.Errorf("making HTTP request: %v", err)
+		return false, fmt.Errorf("making HTTP request: %v", err)
 	}
 
 	logrus.WithField("response", string(response.Body())).Debug("Got response from OpenAI API")
 
-	return nil
+	// Errors aren't worth keeping, since the same request might well work
+	// next time.
+	if response.IsSuccess() {
+		if err := c.cacheResponse(key, url, requestBody, response.Body()); err != nil {
+			return false, err
+		}
+	}
+
+	return false, nil
+}
+
+// cachedResponse returns the cached response for key if the cache mode says
+// to use one, or nil if there isn't one. In replay mode a miss is an error.
+func (c *Client) cachedResponse(key string) ([]byte, error) {
+	if c.cacheMode != CacheOn && c.cacheMode != CacheReplay {
+		return nil, nil
+	}
+
+	// Fixtures are replayed however old they are.
+	ttl := c.cacheTTL
+	if c.cacheMode == CacheReplay {
+		ttl = 0
+	}
+
+	cached, err := c.cache.Get(key, ttl)
+	if err != nil {
+		return nil, err
+	}
+	if cached == nil && c.cacheMode == CacheReplay {
+		return nil, fmt.Errorf("no recorded response for request %s", key)
+	}
+	if cached != nil {
+		logrus.WithField("key", key).Debug("Using cached response from OpenAI API")
+	}
+
+	return cached, nil
+}
+
+// cacheResponse saves the response for key if the cache mode says to.
+func (c *Client) cacheResponse(key, url string, requestBody, responseBody []byte) error {
+	if c.cacheMode != CacheOn && c.cacheMode != CacheRecord {
+		return nil
+	}
+	return c.cache.Put(key, url, requestBody, responseBody)
 }
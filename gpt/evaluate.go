@@ -0,0 +1,110 @@
+package gpt
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/spf13/viper"
+)
+
+// evaluationCriteria are what the evaluator scores, and what it's told each
+// one means.
+var evaluationCriteria = map[string]string{
+	"grammar":     "Is the %s correct, without spelling or grammar mistakes?",
+	"naturalness": "Does it read like something a native speaker wrote, with no words left in English or any other language?",
+	"level":       "Does its vocabulary and grammar suit %s?",
+	"coherence":   "Does the plot make sense and come to a proper ending?",
+	"answerable":  "Can every question be answered from the story, and are the given answers right?",
+}
+
+// Criterion is one thing the evaluator scores, and what it's told that means.
+type Criterion struct {
+	Name        string
+	Description string
+}
+
+// Evaluation is a model's review of a story against evaluationCriteria.
+type Evaluation struct {
+	Scores   map[string]int
+	Comments string
+}
+
+// Problems returns the criteria that scored below their threshold. Criteria
+// without a threshold, or with one of 0, always pass.
+func (e Evaluation) Problems(thresholds map[string]int) []string {
+	var problems []string
+	for _, criterion := range EvaluationCriteria() {
+		threshold := thresholds[criterion]
+		if threshold <= 0 {
+			continue
+		}
+		if score := e.Scores[criterion]; score < threshold {
+			problems = append(problems, fmt.Sprintf("%s scored %d, below %d", criterion, score, threshold))
+		}
+	}
+	return problems
+}
+
+// EvaluationCriteria returns the names of the criteria, in order.
+func EvaluationCriteria() []string {
+	criteria := make([]string, 0, len(evaluationCriteria))
+	for criterion := range evaluationCriteria {
+		criteria = append(criteria, criterion)
+	}
+	sort.Strings(criteria)
+	return criteria
+}
+
+// Evaluate asks openai.evaluation.model, which can be a cheaper model than
+// the one that wrote the story, to score it.
+func (c *Client) Evaluate(story Story) (*Evaluation, error) {
+	data := promptData(story.Style)
+	data.Story = &story
+	audience := "a beginner"
+	if story.Level != "" {
+		audience = "a learner at CEFR level " + story.Level
+	}
+	for _, criterion := range EvaluationCriteria() {
+		description := evaluationCriteria[criterion]
+		switch criterion {
+		case "grammar":
+			description = fmt.Sprintf(description, data.Language)
+		case "level":
+			description = fmt.Sprintf(description, audience)
+		}
+		data.Rubric = append(data.Rubric, Criterion{Name: criterion, Description: description})
+	}
+	prompt, err := renderPrompt("evaluate", data)
+	if err != nil {
+		return nil, err
+	}
+	savePrompt("evaluate", prompt)
+
+	model := viper.GetString("openai.evaluation.model")
+	if model == "" {
+		model = c.model
+	}
+	content, err := c.complete(completionRequest{
+		Model: model,
+		Messages: []completionMessage{
+			{Role: "system", Content: prompt},
+			{Role: "user", Content: story.ToString()},
+		},
+		MaxTokens:      500,
+		N:              1,
+		Temperature:    0,
+		User:           apiUserName,
+		ResponseFormat: &responseFormat{Type: "json_object"},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var evaluation Evaluation
+	if err := json.Unmarshal([]byte(content), &evaluation); err != nil {
+		return nil, fmt.Errorf("decoding evaluation: %v", err)
+	}
+
+	return &evaluation, nil
+}
@@ -1,38 +1,131 @@
 package gpt
 
-import "strings"
+import (
+	"fmt"
+	"strings"
+)
 
 type Story struct {
 	Title       string
 	Description string
 	Story       string
 	Questions   []Question
+	// Characters are the names of the main characters, and Summary is a
+	// sentence or two about the plot in English. We keep both in the story
+	// history, so later stories can avoid them.
+	Characters []string
+	Summary    string
 
-	OriginalJSON string
-	Thumbnail    string
+	// Style is the style we asked for when generating the story. It isn't
+	// part of what the model returns, so it's empty for stories loaded from
+	// disk.
+	Style string
+	// Vocabulary is the vocabulary we sent when generating the story.
+	Vocabulary *Vocabulary
+	// Level and GrammarTargets are what we asked the story to be written for,
+	// and LevelReport is how well it managed.
+	Level          string
+	GrammarTargets []string
+	LevelReport    *LevelReport
+	// Evaluation is a model's review of the story, if we asked for one.
+	Evaluation *Evaluation
+
+	// Scenes are only set when we've illustrated the story.
+	Scenes []Scene
+	// Translation is only set when we've translated the story. It's saved in
+	// the story's JSON, so stories loaded from disk keep theirs.
+	Translation *Translation
+
+	OriginalJSON    string
+	Thumbnail       string
+	ThumbnailPrompt string
 }
 
-type Question struct {
-	Question string
-	Answer   string
+// Paragraphs splits the story into its non-empty paragraphs. Anything that
+// refers to a paragraph by index, like a scene, counts using these.
+func (s Story) Paragraphs() []string {
+	var paragraphs []string
+	for _, paragraph := range strings.Split(s.Story, "\n") {
+		if paragraph = strings.TrimSpace(paragraph); paragraph != "" {
+			paragraphs = append(paragraphs, paragraph)
+		}
+	}
+	return paragraphs
+}
+
+// SceneImagePath is where the illustration for the scene starting at the
+// given paragraph gets written.
+func SceneImagePath(paragraph int) string {
+	return fmt.Sprintf("output-scene-%02d.png", paragraph)
 }
 
 func (s Story) ToString() string {
 	var result strings.Builder
-
-	result.WriteString(s.Title)
-	result.WriteString("\n\n")
-	for _, paragraph := range strings.Split(s.Story, "\n") {
-		result.WriteString(paragraph)
+	for _, segment := range s.Segments() {
+		result.WriteString(segment.Text)
 		result.WriteString("\n\n")
 	}
-	result.WriteString("Preguntas:\n\n")
-	for _, question := range s.Questions {
-		result.WriteString(question.Question)
-		result.WriteString("\n\n")
-		result.WriteString(question.Answer)
-		result.WriteString("\n\n")
+	return result.String()
+}
+
+// Segment is one paragraph of the lesson text, along with its English
+// translation when the story has one that lines up with it.
+type Segment struct {
+	Text        string
+	Translation string
+}
+
+// Segments are the paragraphs of the lesson text in the order they're read:
+// the title, the story with a marker where each illustration goes, the
+// questions and then the answers. The lesson text, its translations and the
+// bilingual version are all built from these, so they always line up.
+func (s Story) Segments() []Segment {
+	translation := s.Translation
+	if !s.Translated() {
+		translation = nil
 	}
 
-	return result.String()
+	illustrated := make(map[int]bool)
+	for _, scene := range s.Scenes {
+		if scene.Image != "" {
+			illustrated[scene.Paragraph] = true
+		}
+	}
+
+	var segments []Segment
+	add := func(text string, translate func(*Translation) string) {
+		segment := Segment{Text: text}
+		if translation != nil {
+			segment.Translation = translate(translation)
+		}
+		segments = append(segments, segment)
+	}
+	same := func(text string) func(*Translation) string {
+		return func(*Translation) string { return text }
+	}
+
+	add(s.Title, func(t *Translation) string { return t.Title })
+	for i, paragraph := range s.Paragraphs() {
+		if illustrated[i] {
+			marker := "[imagen: " + SceneImagePath(i) + "]"
+			add(marker, same(marker))
+		}
+		add(paragraph, func(t *Translation) string { return t.Paragraphs[i] })
+	}
+	// The answers go in a key at the end, so reading the questions doesn't
+	// give them away.
+	add("Preguntas:", same("Questions:"))
+	for i, question := range s.Questions {
+		add(fmt.Sprintf("%d. %s", i+1, question.Prompt()), func(t *Translation) string {
+			return fmt.Sprintf("%d. %s", i+1, t.Questions[i].Question)
+		})
+	}
+	add("Respuestas:", same("Answers:"))
+	for i, question := range s.Questions {
+		add(fmt.Sprintf("%d. %s", i+1, question.AnswerText()), func(t *Translation) string {
+			return fmt.Sprintf("%d. %s", i+1, t.Questions[i].Answer)
+		})
+	}
+
+	return segments
 }
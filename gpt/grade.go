@@ -0,0 +1,50 @@
+package gpt
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Grade is the verdict on a student's answer to a question.
+type Grade struct {
+	Correct  bool
+	Feedback string
+}
+
+// GradeAnswer asks the model whether answer is a good answer to question,
+// for questions that don't have a single right answer to compare against.
+func (c *Client) GradeAnswer(story Story, question Question, answer string) (*Grade, error) {
+	data := promptData(story.Style)
+	data.Story = &story
+	prompt, err := renderPrompt("grade", data)
+	if err != nil {
+		return nil, err
+	}
+	savePrompt("grade", prompt)
+
+	content, err := c.complete(completionRequest{
+		Model: c.model,
+		Messages: []completionMessage{
+			{Role: "system", Content: prompt},
+			{Role: "user", Content: fmt.Sprintf(
+				"Story:\n%s\n\n%s\n\nQuestion: %s\nReference answer: %s\nStudent's answer: %s",
+				story.Title, story.Story, question.Question, question.Answer, answer,
+			)},
+		},
+		MaxTokens:      300,
+		N:              1,
+		Temperature:    0,
+		User:           apiUserName,
+		ResponseFormat: &responseFormat{Type: "json_object"},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var grade Grade
+	if err := json.Unmarshal([]byte(content), &grade); err != nil {
+		return nil, fmt.Errorf("decoding grade: %v", err)
+	}
+
+	return &grade, nil
+}
@@ -3,15 +3,12 @@ package gpt
 import (
 	"fmt"
 
+	"github.com/dpetersen/language-learning/usage"
 	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
 )
 
-const (
-	imageGenerationAPI = "https://api.openai.com/v1/images/generations"
-	imagePrompt        = `
-Create an eye-catching thumbnail in the style of an Audiobook cover for the story that follows. Match the style and intended audience of the image to that of the story:
-`
-)
+const imageGenerationAPI = "https://api.openai.com/v1/images/generations"
 
 type generationRequest struct {
 	Model          string `json:"model"`
@@ -30,29 +27,113 @@ type generationResponse struct {
 	} `json:"data"`
 }
 
-func (c *Client) CreateImage(story string) (string, error) {
+// Image is a generated image along with the prompt DALL-E actually used,
+// which it rewrites from the one we send.
+type Image struct {
+	B64JSON       string
+	RevisedPrompt string
+}
+
+func (c *Client) CreateImage(story Story) (*Image, error) {
+	prompt, err := c.imagePromptFor(story)
+	if err != nil {
+		return nil, fmt.Errorf("building image prompt: %v", err)
+	}
+
+	return c.generateImage(prompt)
+}
+
+func (c *Client) generateImage(prompt string) (*Image, error) {
 	requestObject := generationRequest{
-		Model:          "dall-e-3",
-		Prompt:         imagePrompt + "\n" + firstN(story, 2000),
-		Size:           "1024x1024",
-		Quality:        "standard",
-		Style:          "vivid",
+		Model:          viper.GetString("image.openai.model"),
+		Prompt:         prompt,
+		Size:           viper.GetString("image.openai.size"),
+		Quality:        viper.GetString("image.openai.quality"),
+		Style:          viper.GetString("image.openai.style"),
 		ResponseFormat: "b64_json",
 		User:           apiUserName,
 	}
 
+	reservation, err := usage.Allow(usage.ImageCost(requestObject.Model, requestObject.Size, requestObject.Quality))
+	if err != nil {
+		return nil, err
+	}
+	defer reservation.Release()
+
 	var responseObject generationResponse
-	if err := c.makeAPICall(requestObject, imageGenerationAPI, &responseObject); err != nil {
-		return "", fmt.Errorf("making Image Generation API call: %v", err)
+	cached, err := c.makeAPICall(requestObject, imageGenerationAPI, &responseObject)
+	if err != nil {
+		return nil, fmt.Errorf("making Image Generation API call: %v", err)
 	}
 
 	logrus.WithField("responseObject", responseObject).Debug("Got response from Image Generation API")
+	if !cached {
+		for range responseObject.Data {
+			reservation.RecordImage(requestObject.Model, requestObject.Size, requestObject.Quality)
+		}
+	}
 
 	if len(responseObject.Data) != 1 {
-		return "", fmt.Errorf("unexpected number of data elements in response: %v", len(responseObject.Data))
+		return nil, fmt.Errorf("unexpected number of data elements in response: %v", len(responseObject.Data))
+	}
+
+	return &Image{
+		B64JSON:       responseObject.Data[0].B64JSON,
+		RevisedPrompt: responseObject.Data[0].RevisedPrompt,
+	}, nil
+}
+
+// imagePromptFor builds the image prompt either from the start of the story,
+// or from a description of the whole thing that we ask the chat model for.
+// The latter costs an extra call but stops every cover from being about the
+// first scene.
+func (c *Client) imagePromptFor(story Story) (string, error) {
+	var subject string
+	switch source := viper.GetString("image.prompt_source"); source {
+	case "", "excerpt":
+		subject = firstN(story.Story, 2000)
+	case "summary":
+		description, err := c.DescribeStory(story)
+		if err != nil {
+			return "", fmt.Errorf("describing story: %v", err)
+		}
+		logrus.WithField("description", description).Debug("Described story for image")
+		subject = description
+	default:
+		return "", fmt.Errorf("unknown image prompt source %q", source)
+	}
+
+	data := promptData(story.Style)
+	data.Story, data.Subject = &story, subject
+	prompt, err := renderPrompt("image", data)
+	if err != nil {
+		return "", err
+	}
+	savePrompt("image", prompt)
+
+	return prompt, nil
+}
+
+// DescribeStory asks the chat model for a short English description of the
+// characters, setting and mood of the story.
+func (c *Client) DescribeStory(story Story) (string, error) {
+	prompt, err := renderPrompt("describe", promptData(story.Style))
+	if err != nil {
+		return "", err
 	}
+	savePrompt("describe", prompt)
 
-	return responseObject.Data[0].B64JSON, nil
+	return c.complete(completionRequest{
+		Model: c.model,
+		Messages: []completionMessage{
+			{Role: "system", Content: prompt},
+			{Role: "user", Content: story.Title + "\n\n" + story.Story},
+		},
+		MaxTokens:   300,
+		N:           1,
+		Temperature: 0.3,
+		User:        apiUserName,
+	})
 }
 
 func firstN(s string, n int) string {
@@ -0,0 +1,165 @@
+package gpt
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// CEFR levels in order, with what each one means for the writing, which is
+// what the model actually needs to hear.
+var cefrLevels = []string{"A1", "A2", "B1", "B2", "C1", "C2"}
+
+var cefrDescriptions = map[string]string{
+	"A1": "very short, simple sentences in the present tense, with only the most basic everyday vocabulary",
+	"A2": "short, simple sentences about familiar, everyday situations, mostly in the present and simple past",
+	"B1": "clear, straightforward prose about familiar topics, with the common past tenses and some longer sentences",
+	"B2": "detailed prose with a range of tenses including the subjunctive, and some idiomatic language",
+	"C1": "rich, complex prose with varied sentence structure and idiomatic, nuanced language",
+	"C2": "any register at all, as a skilled native writer would use it",
+}
+
+func validLevel(level string) bool {
+	return levelIndex(level) >= 0
+}
+
+func levelIndex(level string) int {
+	for i, candidate := range cefrLevels {
+		if candidate == level {
+			return i
+		}
+	}
+	return -1
+}
+
+// LevelReport is what we found when checking a story against the level and
+// grammar targets we asked for.
+type LevelReport struct {
+	Level    string
+	Grammar  map[string]bool
+	Comments string
+}
+
+// Problems describes where the story missed what we asked for. A level one
+// step off is close enough, since none of this is an exact science.
+func (r LevelReport) Problems(level string, targets []string) []string {
+	var problems []string
+	if validLevel(level) && validLevel(r.Level) {
+		if diff := levelIndex(r.Level) - levelIndex(level); diff > 1 || diff < -1 {
+			problems = append(problems, fmt.Sprintf("story reads as %s rather than %s", r.Level, level))
+		}
+	}
+	for _, target := range targets {
+		if used, checked := r.Grammar[target]; checked && !used {
+			problems = append(problems, fmt.Sprintf("story doesn't use %s", target))
+		}
+	}
+	return problems
+}
+
+// CheckLevel asks the model what level the story is at, and whether it uses
+// the grammar targets.
+func (c *Client) CheckLevel(story Story, targets []string) (*LevelReport, error) {
+	data := promptData(story.Style)
+	data.Story, data.GrammarTargets = &story, targets
+	prompt, err := renderPrompt("level", data)
+	if err != nil {
+		return nil, err
+	}
+	savePrompt("level", prompt)
+
+	content, err := c.complete(completionRequest{
+		Model: c.model,
+		Messages: []completionMessage{
+			{Role: "system", Content: prompt},
+			{Role: "user", Content: story.Title + "\n\n" + story.Story},
+		},
+		MaxTokens:      400,
+		N:              1,
+		Temperature:    0,
+		User:           apiUserName,
+		ResponseFormat: &responseFormat{Type: "json_object"},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var report LevelReport
+	if err := json.Unmarshal([]byte(content), &report); err != nil {
+		return nil, fmt.Errorf("decoding level check: %v", err)
+	}
+	report.Level = strings.ToUpper(strings.TrimSpace(report.Level))
+
+	return &report, nil
+}
+
+// Rough patterns for grammar we can spot without asking anybody. They're
+// keyed by words that show up in how people usually name the grammar point.
+var grammarPatterns = map[string]*regexp.Regexp{
+	"preterite":   regexp.MustCompile(`\pL{2,}(?:é|aste|ó|amos|aron|iste|ió|imos|ieron)(?:[^\pL]|$)`),
+	"imperfect":   regexp.MustCompile(`\pL{2,}(?:aba|abas|ábamos|aban|ía|ías|íamos|ían)(?:[^\pL]|$)`),
+	"subjunctive": regexp.MustCompile(`(?:^|[^\pL])(?:(?:` + subjunctiveTriggers + `)\s+que|ojalá(?:\s+que)?)\s+\pL{2,}(?:e|es|emos|en|a|as|amos|an)(?:[^\pL]|$)`),
+	"future":      regexp.MustCompile(`\pL{2,}(?:aré|arás|ará|aremos|arán|eré|erás|erá|eremos|erán|iré|irás|irá|iremos|irán)(?:[^\pL]|$)`),
+	"conditional": regexp.MustCompile(`\pL{2,}(?:ría|rías|ríamos|rían)(?:[^\pL]|$)`),
+	"gerund":      regexp.MustCompile(`\pL{2,}(?:ando|iendo|yendo)(?:[^\pL]|$)`),
+}
+
+// A "que" followed by a verb is as likely to be the indicative, as in "dice
+// que hablan", so the subjunctive only counts after the verbs, expressions
+// and conjunctions that call for it.
+const subjunctiveTriggers = `(?:quier|quer|quis|esper|pid|ped|dud|prefier|prefer|necesit|mand|dej|aconsej|recomiend|recomend|sugier|suger|permit|prohib|exig|rueg|rog|dese)\pL*` +
+	`|es\s+(?:importante|necesario|posible|imposible|mejor|probable|raro|una\s+pena)` +
+	`|para|antes\s+de|sin|a\s+menos|con\s+tal\s+de|no\s+creo|me\s+alegro\s+de`
+
+var sentenceBreaks = regexp.MustCompile(`[.!?]+`)
+
+// Average sentence length, in words, that each level tops out at. Past the
+// last one it's C2.
+var sentenceLengthLevels = []float64{8, 11, 15, 20, 25}
+
+// EstimateLevel checks the story locally, guessing its level from how long
+// its sentences are and looking for the grammar targets with some rough
+// patterns. Targets we don't have a pattern for are left out of the report.
+func EstimateLevel(story Story, targets []string) *LevelReport {
+	sentences := sentenceBreaks.Split(story.Story, -1)
+	var words, count int
+	for _, sentence := range sentences {
+		if n := len(strings.Fields(sentence)); n > 0 {
+			words += n
+			count++
+		}
+	}
+
+	report := &LevelReport{Level: cefrLevels[len(cefrLevels)-1], Grammar: map[string]bool{}}
+	if count > 0 {
+		average := float64(words) / float64(count)
+		for i, limit := range sentenceLengthLevels {
+			if average <= limit {
+				report.Level = cefrLevels[i]
+				break
+			}
+		}
+		report.Comments = fmt.Sprintf("sentences average %.1f words", average)
+	}
+
+	text := strings.ToLower(story.Story)
+	for _, target := range targets {
+		var matched, checked bool
+		for name, pattern := range grammarPatterns {
+			if !strings.Contains(strings.ToLower(target), name) {
+				continue
+			}
+			// Every grammar point named in the target has to be there, so
+			// "preterite vs imperfect" needs both.
+			found := pattern.MatchString(text)
+			matched = found && (matched || !checked)
+			checked = true
+		}
+		if checked {
+			report.Grammar[target] = matched
+		}
+	}
+
+	return report
+}
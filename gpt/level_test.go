@@ -0,0 +1,23 @@
+package gpt
+
+import "testing"
+
+func TestEstimateLevelSubjunctive(t *testing.T) {
+	tests := map[string]bool{
+		"Quiero que hables con ella.":         true,
+		"Su madre espera que vengas pronto.":  true,
+		"Lo guarda para que nadie lo coma.":   true,
+		"Es importante que lleguen a tiempo.": true,
+		"Ojalá llueva mañana.":                true,
+		"Dice que hablan muy rápido.":         false,
+		"Es la casa que compran cada verano.": false,
+		"Sabe que la tienda cierra temprano.": false,
+		"Piensa que todos comen demasiado.":   false,
+	}
+	for text, want := range tests {
+		report := EstimateLevel(Story{Story: text}, []string{"present subjunctive"})
+		if got := report.Grammar["present subjunctive"]; got != want {
+			t.Errorf("subjunctive in %q = %v, want %v", text, got, want)
+		}
+	}
+}
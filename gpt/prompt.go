@@ -0,0 +1,202 @@
+package gpt
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/dpetersen/language-learning/history"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// The prompts are text/template files. These are the defaults, and any of
+// them can be replaced by a file of the same name in openai.prompt_dir:
+//
+//   - system.tmpl: who the model is and how it should teach
+//   - format.tmpl: the questions and JSON we want back, and the vocabulary
+//   - user.tmpl: the request for a particular story
+//   - image.tmpl: the prompt for the cover image
+//   - scene.tmpl: the prompt for each scene's illustration
+//   - rewrite.tmpl: the request to fix a story that failed validation
+//   - describe.tmpl: the request for a description of the story to draw the
+//     cover from, when image.prompt_source is summary
+//   - scenes.tmpl: the request to split the story into scenes to illustrate
+//   - level.tmpl: the request to judge the story's level and grammar
+//   - grade.tmpl: the request to grade an answer to a question
+//   - translate.tmpl: the request for an English translation
+//   - continue.tmpl: the request to carry on with a response that was cut off
+//   - evaluate.tmpl: the request to score the story against the rubric
+//
+// On top of the usual template functions, they can use join, which is
+// strings.Join.
+//
+//go:embed prompts/*.tmpl
+var defaultPrompts embed.FS
+
+// PromptData is what every prompt template is rendered with. The story
+// fields are set for every prompt, the rest only for the prompts they're
+// named for. Style is empty for continue, which isn't about any one story.
+type PromptData struct {
+	// Instructions and Preamble are openai.story_instructions and
+	// openai.story_prompt_preamble.
+	Instructions string
+	Preamble     string
+	// Language is the name of the language we're learning, like Spanish, and
+	// LanguageCode is its ISO 639-1 code.
+	Language     string
+	LanguageCode string
+	Style        string
+	// Length is the number of words we'd like the story to be.
+	Length int
+	// Level is a CEFR level like B1, if we're targeting one, and
+	// LevelDescription is what that means in plain English.
+	Level            string
+	LevelDescription string
+	// GrammarTargets are openai.story_grammar_targets, except for level,
+	// where they're the targets to check the story for.
+	GrammarTargets []string
+	// Topic is what the story should be about, if we have an opinion.
+	Topic string
+	// SeriesName is series.name, and SeriesSummary is what has happened in
+	// the series so far, if it's one that carries on from story to story.
+	SeriesName    string
+	SeriesSummary string
+
+	// AvoidTitles, AvoidCharacters and AvoidPremises are from the most recent
+	// stories, so the model doesn't keep telling the same one.
+	AvoidTitles     []string
+	AvoidCharacters []string
+	AvoidPremises   []string
+
+	// Examples are our favorite stories so far, to show the model the tone
+	// and difficulty we like. Each has a Title, Style and Excerpt.
+	Examples []history.Entry
+
+	// Words is the vocabulary for the story, and Vocabulary is the same
+	// thing written out as the lists we've always sent. Only for format.
+	Words      *Vocabulary
+	Vocabulary string
+
+	// Story is the story so far. Only for image, scene, rewrite, describe,
+	// scenes, level, grade, translate and evaluate.
+	Story *Story
+	// Subject is the excerpt or description of the story, or the scene, to
+	// draw. Only for image and scene.
+	Subject string
+	// Problems are what failed validation. Only for rewrite.
+	Problems []string
+	// MaxScenes is the most scenes to split the story into. Only for scenes.
+	MaxScenes int
+	// Rubric is what the story is scored on, in order. Only for evaluate.
+	Rubric []Criterion
+}
+
+// promptData fills in the story fields of PromptData from the config.
+func promptData(style string) PromptData {
+	level := viper.GetString("openai.story_level")
+	return PromptData{
+		Instructions:     viper.GetString("openai.story_instructions"),
+		Preamble:         viper.GetString("openai.story_prompt_preamble"),
+		Language:         languageNames[viper.GetString("language")],
+		LanguageCode:     viper.GetString("language"),
+		Style:            style,
+		Length:           viper.GetInt("openai.story_length"),
+		Level:            level,
+		LevelDescription: cefrDescriptions[level],
+		GrammarTargets:   viper.GetStringSlice("openai.story_grammar_targets"),
+		Topic:            viper.GetString("openai.story_topic"),
+		SeriesName:       viper.GetString("series.name"),
+		SeriesSummary:    viper.GetString("series.summary"),
+	}
+}
+
+// avoid fills in what the prompt should steer clear of from recent stories.
+func (d *PromptData) avoid(recent []history.Entry) {
+	seen := make(map[string]bool)
+	for _, entry := range recent {
+		d.AvoidTitles = append(d.AvoidTitles, entry.Title)
+		if entry.Summary != "" {
+			d.AvoidPremises = append(d.AvoidPremises, entry.Summary)
+		}
+		for _, name := range entry.Characters {
+			if !seen[name] {
+				seen[name] = true
+				d.AvoidCharacters = append(d.AvoidCharacters, name)
+			}
+		}
+	}
+}
+
+// promptFuncs are the functions templates can use on top of the built-in
+// ones.
+var promptFuncs = template.FuncMap{
+	"join": strings.Join,
+}
+
+// renderPrompt renders the named prompt template with data.
+func renderPrompt(name string, data PromptData) (string, error) {
+	text, err := promptTemplate(name)
+	if err != nil {
+		return "", err
+	}
+
+	tmpl, err := template.New(name).Funcs(promptFuncs).Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("parsing %s prompt: %v", name, err)
+	}
+
+	var result strings.Builder
+	if err := tmpl.Execute(&result, data); err != nil {
+		return "", fmt.Errorf("rendering %s prompt: %v", name, err)
+	}
+
+	return strings.TrimSpace(result.String()), nil
+}
+
+func promptTemplate(name string) (string, error) {
+	if dir := viper.GetString("openai.prompt_dir"); dir != "" {
+		text, err := os.ReadFile(filepath.Join(dir, name+".tmpl"))
+		if err == nil {
+			return string(text), nil
+		}
+		if !errors.Is(err, os.ErrNotExist) {
+			return "", fmt.Errorf("reading %s prompt: %v", name, err)
+		}
+	}
+
+	text, err := defaultPrompts.ReadFile("prompts/" + name + ".tmpl")
+	if err != nil {
+		return "", fmt.Errorf("no %s prompt: %v", name, err)
+	}
+	return string(text), nil
+}
+
+// promptRun names the directory savePrompt writes this run's prompts to, so
+// one run doesn't overwrite the last one's.
+var promptRun = time.Now().Format("2006-01-02T15-04-05")
+
+// savePrompt logs a prompt we're about to send, and keeps a copy of it in a
+// directory for this run under openai.prompt_output_dir if that's set, so we
+// can see exactly what each run asked for.
+func savePrompt(name, prompt string) {
+	logrus.WithField("prompt", prompt).Debugf("Rendered %s prompt", name)
+
+	dir := viper.GetString("openai.prompt_output_dir")
+	if dir == "" {
+		return
+	}
+	dir = filepath.Join(dir, promptRun)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		logrus.WithError(err).Warn("Creating prompt output directory")
+		return
+	}
+	if err := os.WriteFile(filepath.Join(dir, name+".txt"), []byte(prompt), 0o644); err != nil {
+		logrus.WithError(err).Warn("Saving rendered prompt")
+	}
+}
@@ -0,0 +1,204 @@
+package gpt
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const (
+	QuestionFree           = "free"
+	QuestionMultipleChoice = "multiple_choice"
+	QuestionTrueFalse      = "true_false"
+	QuestionCloze          = "cloze"
+	QuestionOrdering       = "ordering"
+)
+
+var questionTypes = []string{
+	QuestionFree,
+	QuestionMultipleChoice,
+	QuestionTrueFalse,
+	QuestionCloze,
+	QuestionOrdering,
+}
+
+// The answers to true or false questions, as the model is told to write them.
+const (
+	AnswerTrue  = "verdadero"
+	AnswerFalse = "falso"
+)
+
+// Question is one of the comprehension questions after the story. What the
+// fields hold depends on the type:
+//
+//   - free: an open question and an example answer.
+//   - multiple_choice: the Options, with the Answer being the text of the
+//     correct one.
+//   - true_false: a statement, with the Answer "verdadero" or "falso".
+//   - cloze: a sentence from the story with a word blanked out as "___", and
+//     the missing word as the Answer.
+//   - ordering: events from the story as Options in a jumbled order, with the
+//     Answer being their letters in the right order, like "c, a, b".
+type Question struct {
+	Type     string
+	Question string
+	Options  []string
+	Answer   string
+}
+
+// Kind is the question's type, treating stories from before we had types as
+// all free questions.
+func (q Question) Kind() string {
+	if q.Type == "" {
+		return QuestionFree
+	}
+	return q.Type
+}
+
+var questionInstructions = map[string]string{
+	QuestionMultipleChoice: "Elige la respuesta correcta.",
+	QuestionTrueFalse:      "¿Verdadero o falso?",
+	QuestionCloze:          "Completa la frase.",
+	QuestionOrdering:       "Ordena los eventos.",
+}
+
+// Instruction tells the student what to do with the question, in Spanish, or
+// is empty for free questions.
+func (q Question) Instruction() string {
+	return questionInstructions[q.Kind()]
+}
+
+// OptionLetter returns the letter the option at index i is listed under.
+func OptionLetter(i int) string {
+	return string(rune('a' + i))
+}
+
+// Prompt is the question as it's written in the transcript, with its
+// instruction and options.
+func (q Question) Prompt() string {
+	var result strings.Builder
+	if instruction := q.Instruction(); instruction != "" {
+		result.WriteString(instruction + " ")
+	}
+	result.WriteString(q.Question)
+	for i, option := range q.Options {
+		result.WriteString(fmt.Sprintf("\n   %s) %s", OptionLetter(i), option))
+	}
+	return result.String()
+}
+
+// AnswerText is the answer as it's written in the answer key.
+func (q Question) AnswerText() string {
+	switch q.Kind() {
+	case QuestionMultipleChoice:
+		if i := q.optionIndex(q.Answer); i >= 0 {
+			return fmt.Sprintf("%s) %s", OptionLetter(i), q.Options[i])
+		}
+	case QuestionTrueFalse:
+		switch strings.ToLower(strings.TrimSpace(q.Answer)) {
+		case AnswerTrue:
+			return "Verdadero"
+		case AnswerFalse:
+			return "Falso"
+		}
+	case QuestionOrdering:
+		if order, ok := q.Order(); ok {
+			steps := make([]string, 0, len(order))
+			for _, i := range order {
+				steps = append(steps, fmt.Sprintf("%s) %s", OptionLetter(i), q.Options[i]))
+			}
+			return strings.Join(steps, "; ")
+		}
+	}
+	return q.Answer
+}
+
+func (q Question) optionIndex(text string) int {
+	for i, option := range q.Options {
+		if strings.EqualFold(strings.TrimSpace(option), strings.TrimSpace(text)) {
+			return i
+		}
+	}
+	return -1
+}
+
+var optionLetters = regexp.MustCompile(`[a-zA-Z]`)
+
+// Order parses the answer to an ordering question into option indexes,
+// returning false unless it uses every option exactly once.
+func (q Question) Order() ([]int, bool) {
+	return ParseOrder(q.Answer, len(q.Options))
+}
+
+// ParseOrder reads a list of option letters like "c, a, b" as indexes,
+// returning false unless it's a complete ordering of n options.
+func ParseOrder(s string, n int) ([]int, bool) {
+	letters := optionLetters.FindAllString(strings.ToLower(s), -1)
+	if len(letters) != n {
+		return nil, false
+	}
+
+	seen := make(map[int]bool)
+	order := make([]int, 0, n)
+	for _, letter := range letters {
+		i := int(letter[0] - 'a')
+		if i < 0 || i >= n || seen[i] {
+			return nil, false
+		}
+		seen[i] = true
+		order = append(order, i)
+	}
+	return order, true
+}
+
+var clozeBlank = regexp.MustCompile(`_{3,}`)
+
+// FillBlank returns the question with the blank of a cloze question replaced
+// by with.
+func (q Question) FillBlank(with string) string {
+	return clozeBlank.ReplaceAllLiteralString(q.Question, with)
+}
+
+// validate returns what's wrong with the question, describing it as number.
+func (q Question) validate(number int) []string {
+	var problems []string
+	add := func(format string, args ...any) {
+		problems = append(problems, fmt.Sprintf("question %d ", number)+fmt.Sprintf(format, args...))
+	}
+
+	if strings.TrimSpace(q.Question) == "" {
+		add("is empty")
+	}
+	if strings.TrimSpace(q.Answer) == "" {
+		add("has no answer")
+		return problems
+	}
+
+	switch q.Kind() {
+	case QuestionFree:
+	case QuestionMultipleChoice:
+		if len(q.Options) < 3 {
+			add("needs at least 3 options")
+		} else if q.optionIndex(q.Answer) < 0 {
+			add("has an answer that isn't one of its options")
+		}
+	case QuestionTrueFalse:
+		if answer := strings.ToLower(strings.TrimSpace(q.Answer)); answer != AnswerTrue && answer != AnswerFalse {
+			add("must be answered %q or %q", AnswerTrue, AnswerFalse)
+		}
+	case QuestionCloze:
+		if !clozeBlank.MatchString(q.Question) {
+			add("needs a blank written as ___")
+		}
+	case QuestionOrdering:
+		if len(q.Options) < 3 {
+			add("needs at least 3 events to order")
+		} else if _, ok := q.Order(); !ok {
+			add("must be answered with the letters of all of its options in order, like \"c, a, b\"")
+		}
+	default:
+		add("has unknown type %q, it must be one of %s", q.Type, strings.Join(questionTypes, ", "))
+	}
+
+	return problems
+}
@@ -0,0 +1,96 @@
+package gpt
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Scene is a part of the story we want to illustrate, starting at the given
+// paragraph (as counted by Story.Paragraphs).
+type Scene struct {
+	Paragraph   int
+	Description string
+
+	// Image is the base64-encoded illustration, once there is one.
+	Image string `json:"-"`
+}
+
+// SplitScenes asks the model to break the story up into at most max scenes.
+// With a max of 0 there's nothing to ask for, so there are no scenes.
+func (c *Client) SplitScenes(story Story, max int) ([]Scene, error) {
+	if max <= 0 {
+		return nil, nil
+	}
+
+	var numbered strings.Builder
+	paragraphs := story.Paragraphs()
+	for i, paragraph := range paragraphs {
+		numbered.WriteString(strconv.Itoa(i) + ": " + paragraph + "\n\n")
+	}
+
+	data := promptData(story.Style)
+	data.Story, data.MaxScenes = &story, max
+	prompt, err := renderPrompt("scenes", data)
+	if err != nil {
+		return nil, err
+	}
+	savePrompt("scenes", prompt)
+
+	content, err := c.complete(completionRequest{
+		Model: c.model,
+		Messages: []completionMessage{
+			{Role: "system", Content: prompt},
+			{Role: "user", Content: numbered.String()},
+		},
+		MaxTokens:      150 * max,
+		N:              1,
+		Temperature:    0.3,
+		User:           apiUserName,
+		ResponseFormat: &responseFormat{Type: "json_object"},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var response struct {
+		Scenes []Scene
+	}
+	if err := json.Unmarshal([]byte(content), &response); err != nil {
+		return nil, fmt.Errorf("decoding scenes: %v", err)
+	}
+
+	// The model doesn't always stick to the rules, so drop anything that
+	// points past the end of the story or at a paragraph we already have,
+	// and enforce the limit ourselves.
+	seen := make(map[int]bool)
+	var scenes []Scene
+	for _, scene := range response.Scenes {
+		if scene.Paragraph < 0 || scene.Paragraph >= len(paragraphs) || seen[scene.Paragraph] {
+			continue
+		}
+		seen[scene.Paragraph] = true
+		scenes = append(scenes, scene)
+	}
+	sort.Slice(scenes, func(i, j int) bool { return scenes[i].Paragraph < scenes[j].Paragraph })
+	if len(scenes) > max {
+		scenes = scenes[:max]
+	}
+
+	return scenes, nil
+}
+
+// CreateSceneImage illustrates a single scene of the story.
+func (c *Client) CreateSceneImage(story Story, scene Scene) (*Image, error) {
+	data := promptData(story.Style)
+	data.Story, data.Subject = &story, scene.Description
+	prompt, err := renderPrompt("scene", data)
+	if err != nil {
+		return nil, fmt.Errorf("building scene prompt: %v", err)
+	}
+	savePrompt(fmt.Sprintf("scene-%02d", scene.Paragraph), prompt)
+
+	return c.generateImage(prompt)
+}
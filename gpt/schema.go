@@ -0,0 +1,80 @@
+package gpt
+
+import (
+	"sort"
+	"strings"
+)
+
+// Models that support structured outputs, where the response is guaranteed
+// to match a JSON schema rather than just being some JSON object.
+var jsonSchemaModelPrefixes = []string{
+	"gpt-4o",
+	"gpt-4.1",
+	"gpt-5",
+	"o1",
+	"o3",
+	"o4",
+}
+
+type jsonSchema struct {
+	Name   string         `json:"name"`
+	Strict bool           `json:"strict"`
+	Schema map[string]any `json:"schema"`
+}
+
+// storySchema describes the JSON we want back from CreateStory. In strict mode
+// every property has to be required and nothing else is allowed, so the
+// length and count rules we can't express here are left to Story.Validate.
+var storySchema = newStorySchema()
+
+func newStorySchema() jsonSchema {
+	return jsonSchema{
+		Name:   "story",
+		Strict: true,
+		Schema: object(map[string]any{
+			"title":       stringType,
+			"description": stringType,
+			"story":       stringType,
+			"questions": map[string]any{
+				"type": "array",
+				"items": object(map[string]any{
+					"type":     map[string]any{"type": "string", "enum": questionTypes},
+					"question": stringType,
+					"options":  map[string]any{"type": "array", "items": stringType},
+					"answer":   stringType,
+				}),
+			},
+			"characters": map[string]any{"type": "array", "items": stringType},
+			"summary":    stringType,
+		}),
+	}
+}
+
+var stringType = map[string]any{"type": "string"}
+
+func object(properties map[string]any) map[string]any {
+	required := make([]string, 0, len(properties))
+	for name := range properties {
+		required = append(required, name)
+	}
+	// The request is the cache key, so it has to come out the same every time.
+	sort.Strings(required)
+
+	return map[string]any{
+		"type":                 "object",
+		"properties":           properties,
+		"required":             required,
+		"additionalProperties": false,
+	}
+}
+
+// storyResponseFormat asks for the story schema where the model supports it,
+// and falls back to plain JSON mode everywhere else.
+func storyResponseFormat(model string) *responseFormat {
+	for _, prefix := range jsonSchemaModelPrefixes {
+		if strings.HasPrefix(model, prefix) {
+			return &responseFormat{Type: "json_schema", JSONSchema: &storySchema}
+		}
+	}
+	return &responseFormat{Type: "json_object"}
+}
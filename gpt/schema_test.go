@@ -0,0 +1,36 @@
+package gpt
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+// The marshalled request is the response cache key, so building it again has
+// to give exactly the same bytes or the cache never hits.
+func TestStoryRequestIsStable(t *testing.T) {
+	request := func() []byte {
+		schema := newStorySchema()
+		body, err := json.Marshal(completionRequest{
+			Model:          "gpt-4o",
+			Messages:       []completionMessage{{Role: "user", Content: "Escribe un cuento."}},
+			MaxTokens:      1000,
+			N:              1,
+			ResponseFormat: &responseFormat{Type: "json_schema", JSONSchema: &schema},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return body
+	}
+
+	first := request()
+	for i := 0; i < 50; i++ {
+		if body := request(); !bytes.Equal(body, first) {
+			t.Fatalf("request changed between builds:\n%s\n%s", first, body)
+		}
+	}
+	if !bytes.Contains(first, []byte(`"required":["characters","description","questions","story","summary","title"]`)) {
+		t.Errorf("required properties aren't sorted: %s", first)
+	}
+}
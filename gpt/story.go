@@ -6,42 +6,18 @@ import (
 	"fmt"
 	"math/rand"
 	"os"
+	"sort"
 	"strings"
 
+	"github.com/dpetersen/language-learning/dialect"
+	"github.com/dpetersen/language-learning/history"
 	"github.com/dpetersen/language-learning/lingq"
+	"github.com/dpetersen/language-learning/usage"
+	"github.com/sirupsen/logrus"
 	"github.com/spf13/viper"
 )
 
-const (
-	completionsAPI     = "https://api.openai.com/v1/chat/completions"
-	formatInstructions = `
-After each story, ask the student 5 questions in Spanish about the story. The
-point is to reinforce the vocabulary from the story.
-
-I want the response in the form of a valid JSON object. Here is an example:
-
-{
-	"title": "Juan's Trip to France",
-	"description": "Juan takes a trip to France and learns the true meaning of friendship.",
-	"story": "Once upon a time there was a boy named Juan. He wanted to travel to France. He thought it was a beautiful country.\nHe had a friend named Maria. She wanted to travel to France too. They decided to travel to France together. They had a great time. They learned a lot about French culture. They learned a lot about each other.\nThey became best friends. The end.",
-	"questions": [
-		{
-			"question": "Where does Juan want to travel to?",
-			"answer": "Juan wants to travel to France. He thinks it is a beautiful country."
-		},
-		{
-			"question": "Is Maria Juan's sister?",
-			"answer": "No, Maria is Juan's friend."
-		}
-	]
-}
-
-This example shows the format of the JSON object, but the actual story content
-should conform to whatever the prompt requests.
-
-Here is list of vocabulary that the student knows:
-`
-)
+const completionsAPI = "https://api.openai.com/v1/chat/completions"
 
 type completionMessage struct {
 	Role    string `json:"role"`
@@ -55,18 +31,32 @@ type completionRequest struct {
 	N              int                 `json:"n"`
 	Temperature    float64             `json:"temperature"`
 	User           string              `json:"user"`
-	ResponseFormat struct {
-		Type string `json:"type"`
-	} `json:"response_format"`
+	ResponseFormat *responseFormat     `json:"response_format,omitempty"`
+	Stream         bool                `json:"stream,omitempty"`
+	StreamOptions  *streamOptions      `json:"stream_options,omitempty"`
+}
+
+type responseFormat struct {
+	Type       string      `json:"type"`
+	JSONSchema *jsonSchema `json:"json_schema,omitempty"`
 }
 
 type completionResponse struct {
-	Choices []struct {
-		Message struct {
-			Content string
-		}
-		FinishReason string `json:"finish_reason"`
+	Model   string
+	Usage   completionUsage
+	Choices []completionChoice
+}
+
+type completionUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+}
+
+type completionChoice struct {
+	Message struct {
+		Content string
 	}
+	FinishReason string `json:"finish_reason"`
 }
 
 func (c *Client) LoadStory(path string) (*Story, error) {
@@ -80,53 +70,207 @@ func (c *Client) LoadStory(path string) (*Story, error) {
 		return nil, fmt.Errorf("decoding story: %v", err)
 	}
 
+	if problems := story.Validate(viper.GetString("language")); len(problems) > 0 {
+		logrus.WithField("problems", problems).Warn("Loaded story doesn't pass validation")
+	}
+
 	return story, nil
 }
 
-func (c *Client) CreateStory(words []lingq.Word, threshold int) (*Story, error) {
+// CreateStory writes a new story using the words we know, steering clear of
+// the stories in past.
+func (c *Client) CreateStory(words []lingq.Word, threshold int, past []history.Entry) (*Story, error) {
+	level := viper.GetString("openai.story_level")
+	if level != "" && !validLevel(level) {
+		return nil, fmt.Errorf("unknown CEFR level %q", level)
+	}
+
+	style := pickStyle(past)
+	vocabulary, err := selectVocabulary(words, threshold)
+	if err != nil {
+		return nil, fmt.Errorf("selecting vocabulary: %v", err)
+	}
+
+	messages, maxTokens, err := c.storyMessages(&vocabulary, style, past)
+	if err != nil {
+		return nil, err
+	}
+
 	requestObject := completionRequest{
-		Model: c.model,
-		Messages: []completionMessage{
-			{
-				Role: "system",
-				Content: viper.GetString("openai.story_instructions") +
-					"\n\n" +
-					formatInstructions +
-					wordsByStatus(words, threshold),
-			},
-			{
-				Role:    "user",
-				Content: generatePrompt(),
-			},
-		},
-		// TODO could count the length of the prompt and do this intelligently,
-		// instead of just adding 500
-		MaxTokens:   viper.GetInt("openai.story_length") + 500,
-		N:           1,
-		Temperature: 0.7,
-		User:        apiUserName,
-	}
-	requestObject.ResponseFormat.Type = "json_object"
+		Model:          c.model,
+		Messages:       messages,
+		MaxTokens:      maxTokens,
+		N:              1,
+		Temperature:    0.7,
+		User:           apiUserName,
+		ResponseFormat: storyResponseFormat(c.model),
+	}
+
+	content, err := c.complete(requestObject)
+	if err != nil {
+		return nil, err
+	}
+
+	// The model gets one chance to fix whatever it got wrong, with the
+	// problems spelled out for it.
+	story, problems := parseStory(content)
+	dialectProblems, err := rewritableDialectProblems(story)
+	if err != nil {
+		return nil, err
+	}
+	if len(problems) > 0 || len(dialectProblems) > 0 {
+		logrus.WithFields(logrus.Fields{
+			"problems":        problems,
+			"dialectProblems": dialectProblems,
+		}).Warn("Story failed validation, asking for a repair")
+		problems = append(problems, dialectProblems...)
+
+		data := promptData(style)
+		data.Story, data.Problems = story, problems
+		rewrite, err := renderPrompt("rewrite", data)
+		if err != nil {
+			return nil, err
+		}
+		savePrompt("rewrite", rewrite)
+
+		requestObject.Messages = append(
+			requestObject.Messages,
+			completionMessage{Role: "assistant", Content: content},
+			completionMessage{Role: "user", Content: rewrite},
+		)
+		if requestObject.MaxTokens = remainingTokens(c.model, requestObject.Messages); requestObject.MaxTokens <= 0 {
+			return nil, errors.New("no room left in the context to repair the story")
+		}
+		if content, err = c.complete(requestObject); err != nil {
+			return nil, err
+		}
+
+		// Any dialect slips left aren't worth failing over, and the analysis
+		// reports them.
+		story, problems = parseStory(content)
+		if len(problems) > 0 {
+			return nil, fmt.Errorf("story failed validation after repair: %s", strings.Join(problems, "; "))
+		}
+	}
+	story.Style = style
+	story.Vocabulary = &vocabulary
+	story.Level = level
+	story.GrammarTargets = viper.GetStringSlice("openai.story_grammar_targets")
+
+	return story, nil
+}
+
+// complete makes a chat completion request and returns the content of the
+// only choice, as long as the model actually finished.
+func (c *Client) complete(requestObject completionRequest) (string, error) {
+	var content strings.Builder
+	original := requestObject.Messages
+	for continuation := 0; ; continuation++ {
+		// Budget for the worst case, where the model uses every token it's
+		// allowed.
+		promptTokens := tokenizerFor(requestObject.Model).countMessages(requestObject.Messages)
+		reservation, err := usage.Allow(usage.TokenCost(requestObject.Model, promptTokens, requestObject.MaxTokens))
+		if err != nil {
+			return "", err
+		}
+
+		responseObject, cached, err := c.completion(requestObject)
+		if err == nil && !cached {
+			model := responseObject.Model
+			if model == "" {
+				model = requestObject.Model
+			}
+			reservation.RecordTokens(model, responseObject.Usage.PromptTokens, responseObject.Usage.CompletionTokens)
+		}
+		reservation.Release()
+		if err != nil {
+			return "", fmt.Errorf("calling completions API: %v", err)
+		}
+
+		if len(responseObject.Choices) == 0 {
+			return "", errors.New("no choices in response")
+		}
+
+		choice := responseObject.Choices[0]
+		content.WriteString(choice.Message.Content)
+		switch choice.FinishReason {
+		case "stop":
+			return content.String(), nil
+		case "length", finishInterrupted:
+		default:
+			return "", fmt.Errorf("unexpected finish reason: %v", choice.FinishReason)
+		}
+
+		// The response was cut off, so rather than throw away what we've got,
+		// ask the model to carry on from there.
+		if continuation == maxContinuations {
+			return "", fmt.Errorf("response still unfinished after %d continuations", maxContinuations)
+		}
+		logrus.WithFields(logrus.Fields{
+			"finishReason": choice.FinishReason,
+			"characters":   content.Len(),
+		}).Warn("Response was cut off, asking the model to continue")
+		prompt, err := renderPrompt("continue", promptData(""))
+		if err != nil {
+			return "", err
+		}
+		savePrompt("continue", prompt)
+
+		requestObject.Messages = append(
+			append([]completionMessage(nil), original...),
+			completionMessage{Role: "assistant", Content: content.String()},
+			completionMessage{Role: "user", Content: prompt},
+		)
+		// The rest of a JSON object isn't a JSON object, so the continuation
+		// can't be held to the response format.
+		requestObject.ResponseFormat = nil
+		if requestObject.MaxTokens = remainingTokens(requestObject.Model, requestObject.Messages); requestObject.MaxTokens <= 0 {
+			return "", errors.New("no room left in the context to continue the response")
+		}
+	}
+}
+
+// completion makes a single completion request, streaming it if
+// openai.stream says to.
+func (c *Client) completion(requestObject completionRequest) (*completionResponse, bool, error) {
+	if viper.GetBool("openai.stream") {
+		return c.streamCompletion(requestObject)
+	}
 
 	var responseObject completionResponse
-	if err := c.makeAPICall(requestObject, completionsAPI, &responseObject); err != nil {
-		return nil, fmt.Errorf("calling completions API: %v", err)
+	cached, err := c.makeAPICall(requestObject, completionsAPI, &responseObject)
+	return &responseObject, cached, err
+}
+
+// rewritableDialectProblems lints the story against dialect.region, if
+// dialect.rewrite says to send what it finds back to the model to fix.
+func rewritableDialectProblems(story *Story) ([]string, error) {
+	region := viper.GetString("dialect.region")
+	if story == nil || region == "" || !viper.GetBool("dialect.rewrite") {
+		return nil, nil
 	}
 
-	if len(responseObject.Choices) == 0 {
-		return nil, errors.New("no choices in response")
+	profile, err := dialect.Load(viper.GetString("language"), region)
+	if err != nil {
+		return nil, err
 	}
 
-	if responseObject.Choices[0].FinishReason != "stop" {
-		return nil, fmt.Errorf("unexpected finish reason: %v", responseObject.Choices[0].FinishReason)
+	var problems []string
+	for _, problem := range profile.Lint(story.ToString()) {
+		problems = append(problems, problem.String())
 	}
+	return problems, nil
+}
 
-	story, err := contentJSONToStory(responseObject.Choices[0].Message.Content)
+// parseStory decodes and validates the model's response, returning the
+// problems with it if there are any.
+func parseStory(content string) (*Story, []string) {
+	story, err := contentJSONToStory(content)
 	if err != nil {
-		return nil, fmt.Errorf("decoding story: %v", err)
+		return nil, []string{fmt.Sprintf("the response is not valid JSON: %v", err)}
 	}
 
-	return story, nil
+	return story, story.Validate(viper.GetString("language"))
 }
 
 func contentJSONToStory(s string) (*Story, error) {
@@ -139,6 +283,116 @@ func contentJSONToStory(s string) (*Story, error) {
 	return &story, nil
 }
 
+// storyMessages builds the prompt for a story. If all the known words won't
+// fit in the model's context alongside the story itself, the least useful
+// ones are dropped from the vocabulary until they do, as long as at least
+// openai.vocabulary.min_known of them are left. It also returns how many
+// tokens that leaves for the response.
+func (c *Client) storyMessages(vocabulary *Vocabulary, style string, past []history.Entry) ([]completionMessage, int, error) {
+	t := tokenizerFor(c.model)
+	needed := t.reserve(viper.GetInt("openai.story_length"))
+
+	data := promptData(style)
+	data.avoid(history.Recent(past, viper.GetInt("history.avoid_recent")))
+	if count := viper.GetInt("openai.examples.count"); count > 0 {
+		data.Examples = history.TopRated(past, count, viper.GetInt("openai.examples.min_rating"))
+	}
+	system, err := renderPrompt("system", data)
+	if err != nil {
+		return nil, 0, err
+	}
+	user, err := renderPrompt("user", data)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	build := func(known []lingq.Word) ([]completionMessage, error) {
+		trimmed := *vocabulary
+		trimmed.Known = known
+		data.Words, data.Vocabulary = &trimmed, trimmed.prompt()
+		format, err := renderPrompt("format", data)
+		if err != nil {
+			return nil, err
+		}
+
+		return []completionMessage{
+			{Role: "system", Content: system + "\n\n" + format},
+			{Role: "user", Content: user},
+		}, nil
+	}
+	if _, err := build(vocabulary.Known); err != nil {
+		return nil, 0, err
+	}
+	fits := func(words []lingq.Word) bool {
+		// The templates rendered fine above, so they won't fail now.
+		messages, _ := build(words)
+		return t.contextWindow-t.countMessages(messages) >= needed
+	}
+
+	prioritized := vocabulary.Known
+	if t.contextWindow == 0 {
+		logrus.WithField("model", c.model).Warn("Unknown model, so sending the whole vocabulary without checking it fits in the context")
+	} else if !fits(prioritized) {
+		// More words only ever means more tokens, so we can search for the
+		// longest prefix of the list that still fits.
+		keep := sort.Search(len(prioritized)+1, func(n int) bool { return !fits(prioritized[:n]) }) - 1
+		if minimum := min(viper.GetInt("openai.vocabulary.min_known"), len(prioritized)); keep < minimum {
+			return nil, 0, fmt.Errorf(
+				"only %d of %d known words fit in %s's context next to a %d token story, fewer than openai.vocabulary.min_known (%d): use a model with a bigger context, a shorter openai.story_length, or the sample vocabulary strategy",
+				max(keep, 0), len(prioritized), c.model, needed, minimum,
+			)
+		}
+
+		logrus.WithFields(logrus.Fields{
+			"kept":    keep,
+			"dropped": len(prioritized) - keep,
+		}).Warn("Vocabulary doesn't fit in the context, trimming it")
+		prioritized = prioritized[:keep]
+	}
+	vocabulary.Known = prioritized
+
+	messages, err := build(prioritized)
+	if err != nil {
+		return nil, 0, err
+	}
+	savePrompt("system", messages[0].Content)
+	savePrompt("user", messages[1].Content)
+
+	remaining := remainingTokens(c.model, messages)
+	if remaining <= 0 {
+		return nil, 0, errors.New("prompt leaves no room for a response")
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"promptTokens": t.countMessages(messages),
+		"maxTokens":    remaining,
+		"storyTokens":  needed,
+	}).Debug("Counted prompt tokens")
+
+	return messages, remaining, nil
+}
+
+// prioritizeWords returns the words at or above threshold, most useful first.
+// The ones we know best are the safest for the model to lean on, and among
+// those shorter words tend to be the more common ones.
+func prioritizeWords(words []lingq.Word, threshold int) []lingq.Word {
+	var eligible []lingq.Word
+	for _, word := range words {
+		if word.Status >= threshold {
+			eligible = append(eligible, word)
+		}
+	}
+
+	sort.SliceStable(eligible, func(i, j int) bool {
+		if eligible[i].Status != eligible[j].Status {
+			return eligible[i].Status > eligible[j].Status
+		}
+		return len(eligible[i].Term) < len(eligible[j].Term)
+	})
+
+	return eligible
+}
+
 func wordsByStatus(words []lingq.Word, threshold int) string {
 	statusMap := make(map[int][]string)
 
@@ -160,19 +414,47 @@ func wordsByStatus(words []lingq.Word, threshold int) string {
 	return result.String()
 }
 
-func generatePrompt() string {
+// pickStyle picks a style at random from the ones we haven't used in a
+// while, so we get a spread rather than the same one twice running. Among
+// those, the better a style's stories have been rated the more likely it is
+// to come up: the weight is the square of its average rating, and styles
+// without any ratings count as a 3.
+func pickStyle(past []history.Entry) string {
 	styles := viper.GetStringSlice("openai.story_prompt_styles")
-	style := styles[rand.Intn(len(styles))]
 
-	return fmt.Sprintf(`
-		%s
+	// Rest the styles used in the last half a rotation.
+	resting := make(map[string]bool)
+	for _, entry := range history.Recent(past, len(styles)/2) {
+		resting[entry.Style] = true
+	}
+	var candidates []string
+	for _, style := range styles {
+		if !resting[style] {
+			candidates = append(candidates, style)
+		}
+	}
+	if len(candidates) == 0 {
+		candidates = styles
+	}
 
-		I'd like the style of the story to be %s.
+	ratings := history.StyleRatings(past)
+	weights := make([]float64, len(candidates))
+	var total float64
+	for i, style := range candidates {
+		rating, ok := ratings[style]
+		if !ok {
+			rating = 3
+		}
+		weights[i] = rating * rating
+		total += weights[i]
+	}
 
-		Please make the story in the neighborhood of %d words.
-		`,
-		viper.GetString("openai.story_prompt_preamble"),
-		style,
-		viper.GetInt("openai.story_length"),
-	)
+	pick := rand.Float64() * total
+	for i, weight := range weights {
+		if pick < weight {
+			return candidates[i]
+		}
+		pick -= weight
+	}
+	return candidates[len(candidates)-1]
 }
@@ -0,0 +1,165 @@
+package gpt
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// finishInterrupted is the finish reason we give a streamed response that
+	// broke off before the model said it was done.
+	finishInterrupted = "interrupted"
+	// maxContinuations is how many times we'll ask the model to carry on
+	// with a response that got cut off.
+	maxContinuations = 3
+	progressInterval = 5 * time.Second
+)
+
+type streamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
+}
+
+// streamChunk is one server-sent event of a streamed completion. Usage only
+// comes in the last one, which has no choices.
+type streamChunk struct {
+	Model   string
+	Usage   *completionUsage
+	Choices []struct {
+		Delta struct {
+			Content string
+		}
+		FinishReason string `json:"finish_reason"`
+	}
+}
+
+// streamCompletion makes a completion request with the response streamed
+// back, logging progress as it comes in, and puts the pieces together into
+// the response a request without streaming would have got. If the stream
+// breaks off partway, what did arrive comes back with finishInterrupted as the
+// finish reason, so the caller can ask for the rest.
+func (c *Client) streamCompletion(requestObject completionRequest) (*completionResponse, bool, error) {
+	requestObject.Stream = true
+	requestObject.StreamOptions = &streamOptions{IncludeUsage: true}
+	requestBody, err := json.Marshal(requestObject)
+	if err != nil {
+		return nil, false, fmt.Errorf("serializing request to JSON: %v", err)
+	}
+
+	key := responseCacheKey(completionsAPI, requestBody)
+	cached, err := c.cachedResponse(key)
+	if err != nil {
+		return nil, false, err
+	}
+	if cached != nil {
+		var responseObject completionResponse
+		if err := json.Unmarshal(cached, &responseObject); err != nil {
+			return nil, false, fmt.Errorf("decoding cached response: %v", err)
+		}
+		return &responseObject, true, nil
+	}
+
+	logrus.WithField("requestBody", string(requestBody)).Debug("Streaming request to OpenAI API")
+	response, err := c.client.R().
+		SetAuthToken(c.apiKey).
+		SetHeader("Content-Type", "application/json").
+		SetBody(requestBody).
+		SetDoNotParseResponse(true).
+		Post(completionsAPI)
+	if err != nil {
+		return nil, false, fmt.Errorf("making HTTP request: %v", err)
+	}
+	body := response.RawBody()
+	defer body.Close()
+
+	if !response.IsSuccess() {
+		message, _ := io.ReadAll(body)
+		return nil, false, fmt.Errorf("got unexpected status code %d: %s", response.StatusCode(), message)
+	}
+
+	var content strings.Builder
+	var tokens int
+	finishReason := finishInterrupted
+	responseObject := completionResponse{Model: requestObject.Model}
+	started, lastProgress := time.Now(), time.Now()
+
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data: ")
+		if !ok {
+			continue
+		}
+		if data == "[DONE]" {
+			break
+		}
+
+		var chunk streamChunk
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return nil, false, fmt.Errorf("decoding stream chunk: %v", err)
+		}
+		if chunk.Model != "" {
+			responseObject.Model = chunk.Model
+		}
+		if chunk.Usage != nil {
+			responseObject.Usage = *chunk.Usage
+		}
+		for _, choice := range chunk.Choices {
+			if choice.Delta.Content != "" {
+				content.WriteString(choice.Delta.Content)
+				tokens++
+			}
+			if choice.FinishReason != "" {
+				finishReason = choice.FinishReason
+			}
+		}
+
+		if time.Since(lastProgress) >= progressInterval {
+			lastProgress = time.Now()
+			logrus.WithFields(logrus.Fields{
+				"tokens":  tokens,
+				"elapsed": time.Since(started).Round(time.Second),
+			}).Info("Waiting on the model...")
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		logrus.WithError(err).Warn("Stream broke off, keeping what arrived")
+	}
+
+	// Usage only comes at the very end, so a stream that broke off doesn't
+	// have any, and our own counts will have to do.
+	if responseObject.Usage.CompletionTokens == 0 {
+		responseObject.Usage = completionUsage{
+			PromptTokens:     tokenizerFor(requestObject.Model).countMessages(requestObject.Messages),
+			CompletionTokens: tokens,
+		}
+	}
+
+	choice := completionChoice{FinishReason: finishReason}
+	choice.Message.Content = content.String()
+	responseObject.Choices = []completionChoice{choice}
+
+	logrus.WithFields(logrus.Fields{
+		"tokens":       responseObject.Usage.CompletionTokens,
+		"elapsed":      time.Since(started).Round(time.Second),
+		"finishReason": finishReason,
+	}).Debug("Finished streaming response")
+
+	// Only a response that actually finished is worth replaying.
+	if finishReason != finishInterrupted {
+		assembled, err := json.Marshal(responseObject)
+		if err != nil {
+			return nil, false, fmt.Errorf("marshalling JSON: %v", err)
+		}
+		if err := c.cacheResponse(key, completionsAPI, requestBody, assembled); err != nil {
+			return nil, false, err
+		}
+	}
+
+	return &responseObject, false, nil
+}
@@ -0,0 +1,95 @@
+package gpt
+
+import (
+	"math"
+	"strings"
+)
+
+// tokenizer counts tokens the way a model does, and knows how much room the
+// model has for them.
+type tokenizer struct {
+	*encoding
+	// contextWindow is 0 for models we don't know, since guessing too small
+	// a window would throw away vocabulary for nothing.
+	contextWindow   int
+	maxOutputTokens int
+	// tokensPerWord is how many tokens a word of a Spanish story takes,
+	// measured on testdata/story.json.
+	tokensPerWord float64
+}
+
+// Newer models use the o200k encoding, which has a bigger vocabulary and gets
+// more out of each token, particularly for languages other than English.
+var (
+	cl100k = tokenizer{encoding: cl100kBase, tokensPerWord: 1.7}
+	o200k  = tokenizer{encoding: o200kBase, tokensPerWord: 1.4}
+)
+
+var modelTokenizers = []struct {
+	prefix string
+	tokenizer
+}{
+	// Order matters, since the first matching prefix wins.
+	{"gpt-4o", with(o200k, 128000, 16384)},
+	{"gpt-4.1", with(o200k, 1047576, 32768)},
+	{"gpt-4-turbo", with(cl100k, 128000, 4096)},
+	{"gpt-4-1106", with(cl100k, 128000, 4096)},
+	{"gpt-4-0125", with(cl100k, 128000, 4096)},
+	{"gpt-4-32k", with(cl100k, 32768, 32768)},
+	{"gpt-4", with(cl100k, 8192, 8192)},
+	{"gpt-3.5-turbo", with(cl100k, 16385, 4096)},
+}
+
+func with(t tokenizer, contextWindow, maxOutputTokens int) tokenizer {
+	t.contextWindow = contextWindow
+	t.maxOutputTokens = maxOutputTokens
+	return t
+}
+
+// unknownMaxOutputTokens is what we ask a model we don't know for when we
+// can't work out what's left of its context. Every model we know of allows at
+// least this much, and cut off responses get continued anyway.
+const unknownMaxOutputTokens = 4096
+
+// tokenizerFor returns the tokenizer for model. Models we haven't heard of
+// are counted with cl100k, and have no known context window.
+func tokenizerFor(model string) tokenizer {
+	for _, candidate := range modelTokenizers {
+		if strings.HasPrefix(model, candidate.prefix) {
+			return candidate.tokenizer
+		}
+	}
+	return with(cl100k, 0, unknownMaxOutputTokens)
+}
+
+// reserve is how many tokens to leave for a story of words words. On top of
+// the story itself, the response has the title, description, questions and
+// the rest, which came to about 400 tokens in the sample, and the ratio gets
+// some headroom since some stories use longer words than others.
+func (t tokenizer) reserve(words int) int {
+	return int(math.Ceil(float64(words)*t.tokensPerWord*1.2)) + 600
+}
+
+// Every message is wrapped in a few tokens of formatting, and the reply is
+// primed with a few more.
+const (
+	tokensPerMessage = 3
+	tokensPerReply   = 3
+)
+
+func (t tokenizer) countMessages(messages []completionMessage) int {
+	tokens := tokensPerReply
+	for _, message := range messages {
+		tokens += tokensPerMessage + t.count(message.Role) + t.count(message.Content)
+	}
+	return tokens
+}
+
+// remainingTokens is how many tokens model can respond with after messages.
+func remainingTokens(model string, messages []completionMessage) int {
+	t := tokenizerFor(model)
+	if t.contextWindow == 0 {
+		return t.maxOutputTokens
+	}
+	return min(t.contextWindow-t.countMessages(messages), t.maxOutputTokens)
+}
@@ -0,0 +1,80 @@
+package gpt
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/dpetersen/language-learning/lingq"
+	"github.com/spf13/viper"
+)
+
+func TestCount(t *testing.T) {
+	// These agree with tiktoken.
+	tests := []struct {
+		text          string
+		cl100k, o200k int
+	}{
+		{"hello world", 2, 2},
+		{"tiktoken is great!", 6, 6},
+		{"  leading   spaces\n\n\n   trailing   \t\tx  \r\n y 12345 678\n\n  ", 18, 18},
+		{"I'm sure they'LL it's DON'T  ¿Qué?  —dijo—  año 1999…\u00a0\u00a0hola\u3000mundo 😀😀 ∑∫ é́", 41, 36},
+		{"CamelCaseWord HTTPServer naïve Ñandú/path//x\n/y", 17, 15},
+	}
+	for _, test := range tests {
+		if got := cl100kBase.count(test.text); got != test.cl100k {
+			t.Errorf("cl100k count of %q = %d, want %d", test.text, got, test.cl100k)
+		}
+		if got := o200kBase.count(test.text); got != test.o200k {
+			t.Errorf("o200k count of %q = %d, want %d", test.text, got, test.o200k)
+		}
+	}
+}
+
+func TestReserveCoversSampleStory(t *testing.T) {
+	response, err := os.ReadFile("testdata/story.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var story Story
+	if err := json.Unmarshal(response, &story); err != nil {
+		t.Fatal(err)
+	}
+	words := len(strings.Fields(story.Story))
+
+	for _, tokenizer := range []tokenizer{cl100k, o200k} {
+		if got := float64(tokenizer.count(story.Story)) / float64(words); got > tokenizer.tokensPerWord {
+			t.Errorf("%s story takes %.2f tokens a word, more than tokensPerWord %.2f", tokenizer.name, got, tokenizer.tokensPerWord)
+		}
+		if got, reserve := tokenizer.count(string(response)), tokenizer.reserve(words); got > reserve {
+			t.Errorf("%s response takes %d tokens, more than the %d reserved", tokenizer.name, got, reserve)
+		}
+	}
+}
+
+func TestStoryMessagesRefusesTooFewWords(t *testing.T) {
+	viper.Set("openai.story_length", 3500)
+	viper.Set("openai.vocabulary.min_known", 200)
+	t.Cleanup(viper.Reset)
+
+	var known []lingq.Word
+	for i := 0; i < 1000; i++ {
+		known = append(known, lingq.Word{Term: fmt.Sprintf("palabra%d", i), Status: 3})
+	}
+
+	client := NewClient("", "gpt-4")
+	if _, _, err := client.storyMessages(&Vocabulary{Known: known}, "", nil); err == nil {
+		t.Error("expected an error when no known words fit in gpt-4's context")
+	}
+
+	client = NewClient("", "gpt-4o")
+	vocabulary := Vocabulary{Known: known}
+	if _, _, err := client.storyMessages(&vocabulary, "", nil); err != nil {
+		t.Errorf("unexpected error for gpt-4o: %v", err)
+	}
+	if len(vocabulary.Known) != len(known) {
+		t.Errorf("gpt-4o kept %d of %d known words", len(vocabulary.Known), len(known))
+	}
+}
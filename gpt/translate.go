@@ -0,0 +1,136 @@
+package gpt
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// Translation is an English version of a story, paragraph by paragraph and
+// question by question, so it can sit alongside the original.
+type Translation struct {
+	Title      string                `json:"title"`
+	Paragraphs []string              `json:"paragraphs"`
+	Questions  []QuestionTranslation `json:"questions"`
+}
+
+// QuestionTranslation is the translation of a question as it's shown to the
+// student, options and all, and of its answer.
+type QuestionTranslation struct {
+	Question string `json:"question"`
+	Answer   string `json:"answer"`
+}
+
+// Translate asks the translation model, which can be a cheaper one than the
+// one that wrote the story, for an English translation of it.
+func (c *Client) Translate(story Story) (*Translation, error) {
+	original := Translation{Title: story.Title, Paragraphs: story.Paragraphs()}
+	for _, question := range story.Questions {
+		original.Questions = append(original.Questions, QuestionTranslation{
+			Question: question.Prompt(),
+			Answer:   question.AnswerText(),
+		})
+	}
+	originalJSON, err := json.Marshal(original)
+	if err != nil {
+		return nil, fmt.Errorf("marshalling JSON: %v", err)
+	}
+
+	model := viper.GetString("openai.translation_model")
+	if model == "" {
+		model = c.model
+	}
+	data := promptData(story.Style)
+	data.Story = &story
+	prompt, err := renderPrompt("translate", data)
+	if err != nil {
+		return nil, err
+	}
+	savePrompt("translate", prompt)
+
+	messages := []completionMessage{
+		{Role: "system", Content: prompt},
+		{Role: "user", Content: string(originalJSON)},
+	}
+	content, err := c.complete(completionRequest{
+		Model:          model,
+		Messages:       messages,
+		MaxTokens:      remainingTokens(model, messages),
+		N:              1,
+		Temperature:    0.3,
+		User:           apiUserName,
+		ResponseFormat: &responseFormat{Type: "json_object"},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var translation Translation
+	if err := json.Unmarshal([]byte(content), &translation); err != nil {
+		return nil, fmt.Errorf("decoding translation: %v", err)
+	}
+
+	// Translations are only any use if they line up with the original.
+	if len(translation.Paragraphs) != len(original.Paragraphs) {
+		return nil, fmt.Errorf("translated %d paragraphs, but the story has %d", len(translation.Paragraphs), len(original.Paragraphs))
+	}
+	if len(translation.Questions) != len(original.Questions) {
+		return nil, fmt.Errorf("translated %d questions, but the story has %d", len(translation.Questions), len(original.Questions))
+	}
+
+	return &translation, nil
+}
+
+// SetTranslation attaches the translation to the story, and adds it to the
+// story's JSON so that it's saved and loaded along with everything else.
+func (s *Story) SetTranslation(translation *Translation) error {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(s.OriginalJSON), &fields); err != nil {
+		return fmt.Errorf("decoding story JSON: %v", err)
+	}
+
+	translationJSON, err := json.Marshal(translation)
+	if err != nil {
+		return fmt.Errorf("marshalling JSON: %v", err)
+	}
+	fields["translation"] = translationJSON
+
+	storyJSON, err := json.MarshalIndent(fields, "", "\t")
+	if err != nil {
+		return fmt.Errorf("marshalling JSON: %v", err)
+	}
+
+	s.OriginalJSON = string(storyJSON)
+	s.Translation = translation
+	return nil
+}
+
+// Translated reports whether the story has a translation that lines up with
+// it. A story that's been edited since it was translated might not.
+func (s Story) Translated() bool {
+	return s.Translation != nil &&
+		len(s.Translation.Paragraphs) == len(s.Paragraphs()) &&
+		len(s.Translation.Questions) == len(s.Questions)
+}
+
+// BilingualString is the story and its questions with the English
+// translation after each paragraph, for when reading the original alone is
+// too much. Without a translation that lines up, it's just the story.
+func (s Story) BilingualString() string {
+	if !s.Translated() {
+		return s.ToString()
+	}
+
+	var result strings.Builder
+	for _, segment := range s.Segments() {
+		result.WriteString(segment.Text + "\n")
+		if segment.Translation != segment.Text {
+			result.WriteString(segment.Translation + "\n")
+		}
+		result.WriteString("\n")
+	}
+
+	return result.String()
+}
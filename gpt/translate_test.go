@@ -0,0 +1,65 @@
+package gpt
+
+import (
+	"strings"
+	"testing"
+)
+
+func translatedStory() Story {
+	return Story{
+		Title: "El gato",
+		Story: "Había un gato.\n\nEl gato dormía.",
+		Questions: []Question{
+			{Type: QuestionFree, Question: "¿Qué hacía el gato?", Answer: "Dormía."},
+		},
+		Scenes: []Scene{{Paragraph: 1, Image: "iVBORw0KGgo="}},
+		Translation: &Translation{
+			Title:      "The Cat",
+			Paragraphs: []string{"There was a cat.", "The cat was sleeping."},
+			Questions:  []QuestionTranslation{{Question: "What was the cat doing?", Answer: "Sleeping."}},
+		},
+	}
+}
+
+func TestSegmentsLineUpWithLessonText(t *testing.T) {
+	story := translatedStory()
+	segments := story.Segments()
+
+	// LingQ splits the lesson text on blank lines, and wants one
+	// translation for each of those paragraphs.
+	paragraphs := strings.Split(strings.TrimSuffix(story.ToString(), "\n\n"), "\n\n")
+	if len(paragraphs) != len(segments) {
+		t.Fatalf("lesson text has %d paragraphs, but there are %d segments", len(paragraphs), len(segments))
+	}
+	for i, segment := range segments {
+		if segment.Text != paragraphs[i] {
+			t.Errorf("segment %d is %q, but the lesson text has %q", i, segment.Text, paragraphs[i])
+		}
+		if segment.Translation == "" {
+			t.Errorf("segment %d (%q) has no translation", i, segment.Text)
+		}
+	}
+
+	last := segments[len(segments)-1]
+	if last.Text != "1. Dormía." || last.Translation != "1. Sleeping." {
+		t.Errorf("last segment = %+v, want the translated answer", last)
+	}
+}
+
+func TestBilingualStringFallsBackWhenTranslationDoesntLineUp(t *testing.T) {
+	story := translatedStory()
+	story.Story += "\n\nEl gato despertó."
+	story.Questions = append(story.Questions, Question{Type: QuestionFree, Question: "¿Y luego?", Answer: "Despertó."})
+
+	if story.Translated() {
+		t.Error("Translated() = true for an edited story")
+	}
+	if got, want := story.BilingualString(), story.ToString(); got != want {
+		t.Errorf("BilingualString() = %q, want ToString() %q", got, want)
+	}
+	for _, segment := range story.Segments() {
+		if segment.Translation != "" {
+			t.Errorf("segment %q has translation %q", segment.Text, segment.Translation)
+		}
+	}
+}
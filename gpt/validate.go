@@ -0,0 +1,108 @@
+package gpt
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+const (
+	requiredQuestions    = 5
+	maxTitleLength       = 100
+	maxDescriptionLength = 300
+
+	// The share of common words that have to be from the target language
+	// rather than English for us to believe the story is actually in it.
+	minTargetLanguageShare = 0.9
+)
+
+// Very common words that are a dead giveaway for which language a text is in.
+// Words that are common in both, like "a" and "no", are deliberately left
+// out.
+var stopWords = map[string]map[string]bool{
+	"es": wordSet(`de la que el en y los se del las un por con una su para es al lo
+		como más pero sus le ya fue este ha sí porque esta son entre cuando muy sin
+		sobre también hasta hay donde quien desde todo nos durante todos uno les ni
+		ese eso ellos esto mí antes algunos qué unos yo otro otras otra él tanto esa
+		estos mucho nada muchos cual poco ella estar estas algo nosotros era estaba
+		tenía dijo había`),
+	"en": wordSet(`the and of to in is it that was for on are with his they be at one
+		have this from or had by but not what all were we when your can said there
+		an each which she do how their if will up other about out many then them
+		these so some her would make like him into time two more see my than who its
+		now did get made you`),
+}
+
+func wordSet(words string) map[string]bool {
+	set := make(map[string]bool)
+	for _, word := range strings.Fields(words) {
+		set[word] = true
+	}
+	return set
+}
+
+// Validate returns everything wrong with the story, or nothing if it's fine.
+// The problems are written to be handed straight back to the model.
+func (s Story) Validate(language string) []string {
+	var problems []string
+
+	if strings.TrimSpace(s.Title) == "" {
+		problems = append(problems, "the title is empty")
+	} else if n := len([]rune(s.Title)); n > maxTitleLength {
+		problems = append(problems, fmt.Sprintf("the title is %d characters long, but must be at most %d", n, maxTitleLength))
+	}
+
+	if strings.TrimSpace(s.Description) == "" {
+		problems = append(problems, "the description is empty")
+	} else if n := len([]rune(s.Description)); n > maxDescriptionLength {
+		problems = append(problems, fmt.Sprintf("the description is %d characters long, but must be at most %d", n, maxDescriptionLength))
+	}
+
+	if strings.TrimSpace(s.Story) == "" {
+		problems = append(problems, "the story is empty")
+	} else if share, ok := languageShare(s.Story, language); ok && share < minTargetLanguageShare {
+		problems = append(problems, fmt.Sprintf("the story must be written in %s, but only %.0f%% of it is", languageNames[language], share*100))
+	}
+
+	if len(s.Questions) != requiredQuestions {
+		problems = append(problems, fmt.Sprintf("there are %d questions, but there must be exactly %d", len(s.Questions), requiredQuestions))
+	}
+	for i, question := range s.Questions {
+		problems = append(problems, question.validate(i+1)...)
+	}
+
+	return problems
+}
+
+var languageNames = map[string]string{
+	"es": "Spanish",
+	"en": "English",
+}
+
+// languageShare guesses how much of text is in language rather than English,
+// based on how many of each language's stop words show up. It returns false
+// if there isn't enough to go on.
+func languageShare(text, language string) (float64, bool) {
+	target, english := stopWords[language], stopWords["en"]
+	if target == nil || language == "en" {
+		return 0, false
+	}
+
+	var targetHits, englishHits int
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r)
+	})
+	for _, word := range words {
+		if target[word] {
+			targetHits++
+		} else if english[word] {
+			englishHits++
+		}
+	}
+
+	if targetHits+englishHits < 10 {
+		return 0, false
+	}
+
+	return float64(targetHits) / float64(targetHits+englishHits), true
+}
@@ -0,0 +1,171 @@
+package gpt
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
+
+	"github.com/dpetersen/language-learning/frequency"
+	"github.com/dpetersen/language-learning/lemma"
+	"github.com/dpetersen/language-learning/lingq"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+const (
+	vocabularyAll    = "all"
+	vocabularySample = "sample"
+
+	// Words at or below this status are still being learned, and are the
+	// ones worth reinforcing.
+	maxReinforceStatus = 3
+)
+
+// Vocabulary is what we tell the model about the words the student knows.
+type Vocabulary struct {
+	// Known words the story can lean on freely.
+	Known []lingq.Word
+	// Reinforce are words the student is still learning, that we'd like the
+	// story to use so they get another look at them.
+	Reinforce []lingq.Word
+	// NewWords are common words the student doesn't know yet, that the story
+	// should pick from when it needs a new one.
+	NewWords []string
+	// NewWordBudget is how many words the story may introduce that aren't in
+	// either list, or zero to leave it to the instructions.
+	NewWordBudget int
+	// Lemmatized is set when the known words have been collapsed to their
+	// dictionary forms, so the model needs to know the inflections count too.
+	Lemmatized bool
+}
+
+// selectVocabulary picks the words to send according to
+// openai.vocabulary.strategy. "all" sends every word at or above threshold,
+// which is what we used to do. "sample" sends a bounded random sample of the
+// words we know well, plus a list of words to reinforce.
+func selectVocabulary(words []lingq.Word, threshold int) (Vocabulary, error) {
+	vocabulary := Vocabulary{NewWordBudget: viper.GetInt("openai.vocabulary.new_word_budget")}
+	known := lemma.NewSet(viper.GetString("language"), terms(words))
+
+	switch strategy := viper.GetString("openai.vocabulary.strategy"); strategy {
+	case "", vocabularyAll:
+		vocabulary.Known = prioritizeWords(words, threshold)
+	case vocabularySample:
+		var known, learning []lingq.Word
+		for _, word := range words {
+			if word.Status > maxReinforceStatus {
+				known = append(known, word)
+			} else if word.Status >= 2 {
+				learning = append(learning, word)
+			}
+		}
+		vocabulary.Known = prioritizeWords(sample(known, viper.GetInt("openai.vocabulary.known_sample")), 0)
+		vocabulary.Reinforce = prioritizeWords(sample(learning, viper.GetInt("openai.vocabulary.reinforce_count")), 0)
+	default:
+		return Vocabulary{}, fmt.Errorf("unknown vocabulary strategy %q", strategy)
+	}
+
+	if viper.GetBool("openai.vocabulary.lemmatize") {
+		vocabulary.Known = prioritizeWords(lemmatizeWords(vocabulary.Known, known), 0)
+		vocabulary.Lemmatized = true
+	}
+
+	if n := viper.GetInt("openai.vocabulary.new_word_candidates"); n > 0 {
+		newWords, err := commonUnknownWords(known, n)
+		if err != nil {
+			// Without a list the model just picks new words itself, like it
+			// always has.
+			logrus.WithError(err).Warn("Not suggesting new words")
+		}
+		vocabulary.NewWords = newWords
+	}
+
+	return vocabulary, nil
+}
+
+// commonUnknownWords returns the n most common words in the language that
+// aren't in any form we know.
+func commonUnknownWords(known *lemma.Set, n int) ([]string, error) {
+	list, err := frequency.Load(viper.GetString("language"))
+	if err != nil {
+		return nil, err
+	}
+
+	return list.Top(n, known.Contains), nil
+}
+
+// lemmatizeWords collapses words down to their dictionary forms, so "hablaba"
+// and "hablé" are sent once as "hablar". A word only moves to a lemma the
+// lexicon gives for it, or to one that's in the vocabulary anyway, since the
+// rules alone would turn "mesa" into "mesar". Each lemma gets the best status
+// of any of its forms.
+func lemmatizeWords(words []lingq.Word, known *lemma.Set) []lingq.Word {
+	statuses := make(map[string]int)
+	var order []string
+	for _, word := range words {
+		base := known.DictionaryForm(word.Term)
+		if _, seen := statuses[base]; !seen {
+			order = append(order, base)
+		}
+		statuses[base] = max(statuses[base], word.Status)
+	}
+
+	lemmatized := make([]lingq.Word, 0, len(order))
+	for _, term := range order {
+		lemmatized = append(lemmatized, lingq.Word{Term: term, Status: statuses[term]})
+	}
+	return lemmatized
+}
+
+func terms(words []lingq.Word) []string {
+	result := make([]string, 0, len(words))
+	for _, word := range words {
+		result = append(result, word.Term)
+	}
+	return result
+}
+
+// sample returns up to n words picked at random.
+func sample(words []lingq.Word, n int) []lingq.Word {
+	if n >= len(words) {
+		return words
+	}
+
+	shuffled := make([]lingq.Word, len(words))
+	copy(shuffled, words)
+	rand.Shuffle(len(shuffled), func(i, j int) { shuffled[i], shuffled[j] = shuffled[j], shuffled[i] })
+	return shuffled[:n]
+}
+
+func (v Vocabulary) prompt() string {
+	var result strings.Builder
+	if v.Lemmatized {
+		result.WriteString("These are dictionary forms. The student also knows every conjugation and inflection of them, so use those freely.\n\n")
+	}
+	result.WriteString(wordsByStatus(v.Known, 0))
+
+	if len(v.Reinforce) > 0 {
+		terms := make([]string, 0, len(v.Reinforce))
+		for _, word := range v.Reinforce {
+			terms = append(terms, word.Term)
+		}
+		sort.Strings(terms)
+
+		result.WriteString("The student is still learning these words. Please reuse as many of them as fit naturally in the story, so they can practice them:\n")
+		result.WriteString(strings.Join(terms, ","))
+		result.WriteString("\n\n")
+	}
+
+	if len(v.NewWords) > 0 {
+		result.WriteString("When you need a word that isn't in the lists above, choose one of these common words if you can:\n")
+		result.WriteString(strings.Join(v.NewWords, ","))
+		result.WriteString("\n\n")
+	}
+
+	if v.NewWordBudget > 0 {
+		result.WriteString(fmt.Sprintf("Introduce at most %d words that aren't in the lists above.\n\n", v.NewWordBudget))
+	}
+
+	return result.String()
+}
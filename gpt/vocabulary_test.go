@@ -0,0 +1,34 @@
+package gpt
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/dpetersen/language-learning/lemma"
+	"github.com/dpetersen/language-learning/lingq"
+)
+
+func TestLemmatizeWords(t *testing.T) {
+	words := []lingq.Word{
+		{Term: "hablaba", Status: 3},
+		{Term: "hablar", Status: 1},
+		{Term: "Hablé", Status: 2},
+		{Term: "tengo", Status: 4},
+		{Term: "mesa", Status: 3},
+		{Term: "paso", Status: 2},
+		{Term: "Paso", Status: 4},
+		{Term: "pasar", Status: 1},
+	}
+	known := lemma.NewSet("es", terms(words))
+
+	want := []lingq.Word{
+		{Term: "hablar", Status: 3},
+		{Term: "tener", Status: 4},
+		// "mesar" isn't in the vocabulary, so "mesa" stays a noun.
+		{Term: "mesa", Status: 3},
+		{Term: "pasar", Status: 4},
+	}
+	if got := lemmatizeWords(words, known); !reflect.DeepEqual(got, want) {
+		t.Errorf("lemmatizeWords() = %v, want %v", got, want)
+	}
+}
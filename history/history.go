@@ -0,0 +1,118 @@
+// Package history keeps a record of the stories we've generated, so new ones
+// can steer clear of the titles, characters and plots we've already had.
+package history
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// Entry is what we remember about a story.
+type Entry struct {
+	Time        time.Time
+	Title       string
+	Description string
+	Style       string
+	Characters  []string
+	// Summary is a sentence or two about the plot, in English.
+	Summary string
+	// Excerpt is the opening of the story, for showing the model what a story
+	// we liked reads like.
+	Excerpt string `json:",omitempty"`
+
+	// Rating is from 1 to 5, or 0 if we haven't rated the story.
+	Rating int    `json:",omitempty"`
+	Notes  string `json:",omitempty"`
+}
+
+// History is a file of every story we've generated.
+type History struct {
+	path string
+}
+
+func NewHistory(path string) *History {
+	return &History{path: path}
+}
+
+// Load returns every story so far, oldest first.
+func (h *History) Load() ([]Entry, error) {
+	data, err := os.ReadFile(h.path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("reading story history: %v", err)
+	}
+
+	var entries []Entry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("deserializing story history: %v", err)
+	}
+
+	return entries, nil
+}
+
+func (h *History) Append(entry Entry) error {
+	entries, err := h.Load()
+	if err != nil {
+		return err
+	}
+
+	return h.save(append(entries, entry))
+}
+
+// Rate rates the most recent story with the given title, or the most recent
+// story of all if title is empty.
+func (h *History) Rate(title string, rating int, notes string) (*Entry, error) {
+	if rating < 1 || rating > 5 {
+		return nil, fmt.Errorf("rating must be from 1 to 5, not %d", rating)
+	}
+
+	entries, err := h.Load()
+	if err != nil {
+		return nil, err
+	}
+
+	for i := len(entries) - 1; i >= 0; i-- {
+		if title != "" && !strings.EqualFold(entries[i].Title, title) {
+			continue
+		}
+
+		entries[i].Rating, entries[i].Notes = rating, notes
+		if err := h.save(entries); err != nil {
+			return nil, err
+		}
+		return &entries[i], nil
+	}
+
+	if title == "" {
+		return nil, errors.New("there are no stories to rate")
+	}
+	return nil, fmt.Errorf("no story called %q", title)
+}
+
+func (h *History) save(entries []Entry) error {
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshalling JSON: %v", err)
+	}
+
+	if err := os.WriteFile(h.path, data, 0o644); err != nil {
+		return fmt.Errorf("writing story history: %v", err)
+	}
+
+	return nil
+}
+
+// Recent returns the last n entries, most recent first.
+func Recent(entries []Entry, n int) []Entry {
+	var recent []Entry
+	for i := len(entries) - 1; i >= 0 && len(recent) < n; i-- {
+		recent = append(recent, entries[i])
+	}
+	return recent
+}
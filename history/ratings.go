@@ -0,0 +1,39 @@
+package history
+
+import "sort"
+
+// StyleRatings returns the average rating of each style that has any rated
+// stories.
+func StyleRatings(entries []Entry) map[string]float64 {
+	totals := make(map[string]int)
+	counts := make(map[string]int)
+	for _, entry := range entries {
+		if entry.Rating > 0 {
+			totals[entry.Style] += entry.Rating
+			counts[entry.Style]++
+		}
+	}
+
+	averages := make(map[string]float64, len(counts))
+	for style, count := range counts {
+		averages[style] = float64(totals[style]) / float64(count)
+	}
+	return averages
+}
+
+// TopRated returns up to n stories rated at least minRating that have an
+// excerpt to show, best first and the most recent first among equals.
+func TopRated(entries []Entry, n, minRating int) []Entry {
+	var rated []Entry
+	for i := len(entries) - 1; i >= 0; i-- {
+		if entries[i].Rating >= minRating && entries[i].Excerpt != "" {
+			rated = append(rated, entries[i])
+		}
+	}
+
+	sort.SliceStable(rated, func(i, j int) bool { return rated[i].Rating > rated[j].Rating })
+	if len(rated) > n {
+		rated = rated[:n]
+	}
+	return rated
+}
@@ -0,0 +1,59 @@
+package history
+
+import (
+	"strings"
+	"unicode"
+)
+
+// FindDuplicate returns the past story that entry looks like a retelling of,
+// or nil if there isn't one. That's one with the same title, or where the
+// share of words the descriptions and summaries have in common is at least
+// threshold.
+func FindDuplicate(entry Entry, past []Entry, threshold float64) (*Entry, float64) {
+	title := strings.Join(words(entry.Title), " ")
+	premise := premiseWords(entry)
+
+	for i := range past {
+		if title != "" && title == strings.Join(words(past[i].Title), " ") {
+			return &past[i], 1
+		}
+		if similarity := jaccard(premise, premiseWords(past[i])); similarity >= threshold {
+			return &past[i], similarity
+		}
+	}
+
+	return nil, 0
+}
+
+// premiseWords are the words of the description and summary that say
+// something about the plot. Short words are mostly articles and prepositions,
+// which every story has in common.
+func premiseWords(entry Entry) map[string]bool {
+	set := make(map[string]bool)
+	for _, word := range words(entry.Description + " " + entry.Summary) {
+		if len([]rune(word)) > 3 {
+			set[word] = true
+		}
+	}
+	return set
+}
+
+func words(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r)
+	})
+}
+
+func jaccard(a, b map[string]bool) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+
+	var shared int
+	for word := range a {
+		if b[word] {
+			shared++
+		}
+	}
+	return float64(shared) / float64(len(a)+len(b)-shared)
+}
@@ -0,0 +1,140 @@
+// Package lemma maps inflected words back to their dictionary forms, so
+// that knowing "hablar" counts for "hablaba", "hablé" and "habláramos" too.
+package lemma
+
+import (
+	"strings"
+	"sync"
+)
+
+// Lemmatizer returns the dictionary forms a word could be an inflection of.
+// These are guesses: rules happily turn "mesa" into "mesar" as well as
+// "mesa", so the candidates are only useful checked against a list of words
+// that actually exist, like our vocabulary.
+type Lemmatizer interface {
+	// Lemmas returns the word itself, lower cased, followed by every lemma it
+	// could be a form of.
+	Lemmas(word string) []string
+	// Known returns the lemma for an irregular form we know for certain, or
+	// false if there isn't one.
+	Known(word string) (string, bool)
+	// IsLemma reports whether word looks like a dictionary form that other
+	// forms inflect from, like an infinitive.
+	IsLemma(word string) bool
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = map[string]Lemmatizer{}
+)
+
+// Register makes a lemmatizer available for language, which is an ISO 639-1
+// code like "es". Each language's implementation registers itself in init.
+func Register(language string, lemmatizer Lemmatizer) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	registry[language] = lemmatizer
+}
+
+// For returns the lemmatizer for language. Languages without one get a
+// lemmatizer that only knows each word as itself, which is what plain string
+// matching would do.
+func For(language string) Lemmatizer {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	if lemmatizer, ok := registry[language]; ok {
+		return lemmatizer
+	}
+	return identity{}
+}
+
+type identity struct{}
+
+func (identity) Lemmas(word string) []string      { return []string{strings.ToLower(word)} }
+func (identity) Known(word string) (string, bool) { return "", false }
+func (identity) IsLemma(word string) bool         { return false }
+
+// Set is a set of words that also matches their inflected forms.
+type Set struct {
+	lemmatizer Lemmatizer
+	// direct holds just the words we were given, and words those plus the
+	// lemmas the lexicon gives for them.
+	direct map[string]bool
+	words  map[string]bool
+}
+
+// NewSet builds a set out of words. Knowing any form of a verb means knowing
+// the verb, but the rules can't tell "casa" the noun from a form of "casar",
+// so a word's lemmas are only added when the lexicon says so, or when they're
+// one of the words we were given anyway.
+func NewSet(language string, words []string) *Set {
+	set := &Set{
+		lemmatizer: For(language),
+		direct:     make(map[string]bool, len(words)),
+		words:      make(map[string]bool, len(words)),
+	}
+	for _, word := range words {
+		word = set.lemmatizer.Lemmas(word)[0]
+		set.direct[word] = true
+		set.words[word] = true
+	}
+	for _, word := range words {
+		if lemma, ok := set.lemmatizer.Known(word); ok {
+			set.words[lemma] = true
+		}
+	}
+	return set
+}
+
+// Contains reports whether word, or any lemma it could be a form of, is in
+// the set.
+func (s *Set) Contains(word string) bool {
+	for _, candidate := range s.lemmatizer.Lemmas(word) {
+		if s.words[candidate] {
+			return true
+		}
+	}
+	return false
+}
+
+// Lemma returns the dictionary form of word, as best we can tell. A word the
+// set was built from is its own lemma, since "casa" and "vino" are words in
+// their own right. Otherwise it's an irregular form we know for certain, or a
+// candidate lemma that was one of the words the set was built from, or else
+// the word itself, lower cased.
+func (s *Set) Lemma(word string) string {
+	candidates := s.lemmatizer.Lemmas(word)
+	if s.direct[candidates[0]] {
+		return candidates[0]
+	}
+	if lemma, ok := s.lemmatizer.Known(word); ok {
+		return lemma
+	}
+
+	for _, candidate := range candidates[1:] {
+		if s.direct[candidate] {
+			return candidate
+		}
+	}
+	return candidates[0]
+}
+
+// DictionaryForm returns the lemma to send in place of word when every form
+// of it counts as known. Unlike Lemma, a word the set was built from doesn't
+// stand for itself: "vino" becomes "venir" since the lexicon says so, and
+// "casa" becomes "casar" when "casar" is in the set too. Knowing every form of
+// "casar" still covers the noun, which is spelled the same. Words with no
+// lemma we can be sure of are returned lower cased.
+func (s *Set) DictionaryForm(word string) string {
+	if lemma, ok := s.lemmatizer.Known(word); ok {
+		return lemma
+	}
+
+	candidates := s.lemmatizer.Lemmas(word)
+	for _, candidate := range candidates[1:] {
+		if s.direct[candidate] {
+			return candidate
+		}
+	}
+	return candidates[0]
+}
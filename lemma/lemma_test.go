@@ -0,0 +1,72 @@
+package lemma
+
+import "testing"
+
+func TestSetLemma(t *testing.T) {
+	set := NewSet("es", []string{"casa", "casar", "paso", "pasar", "vino", "sola", "solar", "hablar", "ir"})
+
+	tests := map[string]string{
+		// Words we know are their own lemmas, even when they could be a form
+		// of a verb we also know.
+		"casa": "casa",
+		"Paso": "paso",
+		"vino": "vino",
+		"sola": "sola",
+		// Other forms still find the verb.
+		"hablaba":  "hablar",
+		"hablaron": "hablar",
+		"casaron":  "casar",
+		"vamos":    "ir",
+		"vinieron": "venir",
+		"cantaba":  "cantaba",
+	}
+	for word, want := range tests {
+		if got := set.Lemma(word); got != want {
+			t.Errorf("Lemma(%q) = %q, want %q", word, got, want)
+		}
+	}
+}
+
+func TestSetDictionaryForm(t *testing.T) {
+	set := NewSet("es", []string{"casa", "casar", "mesa", "hablaba", "Hablar", "vino"})
+
+	tests := map[string]string{
+		"casa":    "casar",
+		"mesa":    "mesa",
+		"hablaba": "hablar",
+		"Hablar":  "hablar",
+		"vino":    "venir",
+		"tengo":   "tener",
+		"cantaba": "cantaba",
+	}
+	for word, want := range tests {
+		if got := set.DictionaryForm(word); got != want {
+			t.Errorf("DictionaryForm(%q) = %q, want %q", word, got, want)
+		}
+	}
+}
+
+func TestSetContains(t *testing.T) {
+	set := NewSet("es", []string{"casa", "mes", "hablar", "fue", "Tiene"})
+
+	tests := map[string]bool{
+		"casa":  true,
+		"casas": true,
+		"meses": true,
+		"habló": true,
+		"tiene": true,
+		// The lexicon knows these are forms of "ser" and "tener".
+		"fuimos":  true,
+		"tenemos": true,
+		// Guessed lemmas of the words we know aren't known themselves.
+		"caso":    false,
+		"casaron": false,
+		"casar":   false,
+		"mesar":   false,
+	}
+	for word, want := range tests {
+		if got := set.Contains(word); got != want {
+			t.Errorf("Contains(%q) = %v, want %v", word, got, want)
+		}
+	}
+}
@@ -0,0 +1,269 @@
+package lemma
+
+import (
+	"bufio"
+	"embed"
+	"sort"
+	"strings"
+)
+
+//go:embed lexicon/*.txt
+var lexicons embed.FS
+
+func init() {
+	Register("es", newSpanish())
+}
+
+// spanish works out lemmas with a lexicon of irregular forms, and suffix rules
+// for everything else. The rules cover regular conjugations, the common stem
+// and spelling changes, pronouns tacked onto the end of verbs, and plural,
+// feminine, diminutive and adverb forms of nouns and adjectives.
+type spanish struct {
+	lexicon map[string][]string
+}
+
+func newSpanish() *spanish {
+	s := &spanish{lexicon: make(map[string][]string)}
+
+	// The lexicon is embedded, so there's nothing that can go wrong reading
+	// it short of a broken build.
+	file, err := lexicons.Open("lexicon/es.txt")
+	if err != nil {
+		panic(err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		lemma, forms, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		for _, form := range strings.Fields(forms) {
+			s.lexicon[form] = append(s.lexicon[form], strings.TrimSpace(lemma))
+		}
+	}
+
+	return s
+}
+
+var infinitiveEndings = []string{"ar", "er", "ir", "ír"}
+
+// verbEndings lists each ending with the infinitive endings a verb with it
+// could have, longest ending first. Future and conditional forms are handled
+// separately, since they're built on the whole infinitive rather than the
+// stem.
+var verbEndings = buildVerbEndings()
+
+type verbEnding struct {
+	ending      string
+	infinitives []string
+}
+
+func buildVerbEndings() []verbEnding {
+	byEnding := map[string][]string{}
+	add := func(infinitive string, endings string) {
+		for _, ending := range strings.Fields(endings) {
+			byEnding[ending] = append(byEnding[ending], infinitive)
+		}
+	}
+
+	add("ar", `o as a amos áis an é aste ó asteis aron aba abas ábamos abais aban
+		e es emos éis en ara aras áramos arais aran ase ases ásemos aseis asen
+		ando ado ada ados adas ad`)
+	shared := `o es e en í iste ió isteis ieron ía ías íamos íais ían a as amos áis an
+		iera ieras iéramos ierais ieran iese ieses iésemos ieseis iesen iendo ido
+		ida idos idas yó yeron yera yeras yendo`
+	add("er", shared+" emos éis ed")
+	add("ir", shared+" imos ís id")
+
+	endings := make([]verbEnding, 0, len(byEnding))
+	for ending, infinitives := range byEnding {
+		endings = append(endings, verbEnding{ending: ending, infinitives: infinitives})
+	}
+	sort.Slice(endings, func(i, j int) bool {
+		if len(endings[i].ending) != len(endings[j].ending) {
+			return len(endings[i].ending) > len(endings[j].ending)
+		}
+		return endings[i].ending < endings[j].ending
+	})
+	return endings
+}
+
+var futureEndings = strings.Fields("é ás á emos éis án ía ías íamos íais ían")
+
+// Pronouns that can be attached to the end of an infinitive, gerund or
+// command, longest first so "selo" is tried before "lo".
+var enclitics = strings.Fields("selos selas selo sela seles sele melos melas melo mela telos telas telo tela noslo nosla los las les nos lo la le me te se os")
+
+func (s *spanish) Known(word string) (string, bool) {
+	lemmas := s.lexicon[strings.ToLower(word)]
+	if len(lemmas) == 0 {
+		return "", false
+	}
+	return lemmas[0], true
+}
+
+func (s *spanish) IsLemma(word string) bool {
+	if word == "ir" {
+		return true
+	}
+	for _, ending := range infinitiveEndings {
+		if _, ok := trimSuffix(word, ending); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *spanish) Lemmas(word string) []string {
+	word = strings.ToLower(word)
+	candidates := newCandidates(word)
+
+	candidates.add(s.lexicon[word]...)
+	s.verbLemmas(word, candidates)
+	s.encliticLemmas(word, candidates)
+	nominalLemmas(word, candidates)
+
+	return candidates.list
+}
+
+func (s *spanish) verbLemmas(word string, candidates *candidateList) {
+	for _, ending := range verbEndings {
+		stem, ok := trimSuffix(word, ending.ending)
+		if !ok || len([]rune(stem)) < 2 {
+			continue
+		}
+		for _, variant := range stemVariants(stem) {
+			for _, infinitive := range ending.infinitives {
+				candidates.add(variant + infinitive)
+			}
+		}
+	}
+
+	for _, ending := range futureEndings {
+		if infinitive, ok := trimSuffix(word, ending); ok && s.IsLemma(infinitive) {
+			candidates.add(infinitive)
+		}
+	}
+}
+
+// encliticLemmas handles "dámelo", "comerlo" and "diciéndole", where the
+// pronouns are written as part of the verb and the verb picks up an accent to
+// keep its stress.
+func (s *spanish) encliticLemmas(word string, candidates *candidateList) {
+	for _, pronoun := range enclitics {
+		verb, ok := trimSuffix(word, pronoun)
+		if !ok || len([]rune(verb)) < 2 {
+			continue
+		}
+		verb = removeAccents(verb)
+
+		switch {
+		case s.IsLemma(verb):
+			candidates.add(verb)
+		case strings.HasSuffix(verb, "ando"):
+			candidates.add(strings.TrimSuffix(verb, "ando") + "ar")
+		case strings.HasSuffix(verb, "iendo"), strings.HasSuffix(verb, "yendo"):
+			for _, stem := range stemVariants(verb[:len(verb)-len("iendo")]) {
+				candidates.add(stem+"er", stem+"ir")
+			}
+		default:
+			// Probably a command, like "dime" or "cómpralo".
+			candidates.add(s.lexicon[verb]...)
+			s.verbLemmas(verb, candidates)
+		}
+	}
+}
+
+func nominalLemmas(word string, candidates *candidateList) {
+	singulars := []string{word}
+	if stem, ok := trimSuffix(word, "ces"); ok {
+		singulars = append(singulars, stem+"z")
+	}
+	if stem, ok := trimSuffix(word, "es"); ok {
+		singulars = append(singulars, stem)
+	}
+	if stem, ok := trimSuffix(word, "s"); ok {
+		singulars = append(singulars, stem)
+	}
+
+	for _, singular := range singulars {
+		candidates.add(singular)
+		masculine := singular
+		if stem, ok := trimSuffix(singular, "a"); ok {
+			masculine = stem + "o"
+			candidates.add(masculine)
+		}
+		for _, diminutive := range []string{"ito", "cito", "illo"} {
+			if stem, ok := trimSuffix(masculine, diminutive); ok && len([]rune(stem)) > 1 {
+				candidates.add(stem+"o", stem+"a", stem)
+			}
+		}
+		if stem, ok := trimSuffix(masculine, "ísimo"); ok {
+			candidates.add(stem+"o", stem+"e")
+		}
+	}
+
+	if adjective, ok := trimSuffix(word, "mente"); ok {
+		candidates.add(adjective)
+		if stem, ok := trimSuffix(adjective, "a"); ok {
+			candidates.add(stem + "o")
+		}
+	}
+}
+
+// stemVariants undoes the stem and spelling changes that are regular enough
+// to guess at: "pued" could be "pod", "pid" could be "ped", "busqu" could be
+// "busc" and so on.
+func stemVariants(stem string) []string {
+	variants := []string{stem}
+	for _, change := range [][2]string{{"ue", "o"}, {"ie", "e"}, {"i", "e"}, {"u", "o"}} {
+		if i := strings.LastIndex(stem, change[0]); i > 0 {
+			variants = append(variants, stem[:i]+change[1]+stem[i+len(change[0]):])
+		}
+	}
+	for _, change := range [][2]string{{"qu", "c"}, {"gu", "g"}, {"gü", "gu"}, {"zc", "c"}, {"c", "z"}, {"j", "g"}} {
+		if trimmed, ok := trimSuffix(stem, change[0]); ok {
+			variants = append(variants, trimmed+change[1])
+		}
+	}
+	return variants
+}
+
+func trimSuffix(word, suffix string) (string, bool) {
+	if !strings.HasSuffix(word, suffix) || len(word) == len(suffix) {
+		return "", false
+	}
+	return word[:len(word)-len(suffix)], true
+}
+
+var accentRemover = strings.NewReplacer("á", "a", "é", "e", "í", "i", "ó", "o", "ú", "u")
+
+func removeAccents(s string) string {
+	return accentRemover.Replace(s)
+}
+
+// candidateList keeps candidates in the order they were found, without
+// repeats.
+type candidateList struct {
+	list []string
+	seen map[string]bool
+}
+
+func newCandidates(word string) *candidateList {
+	return &candidateList{list: []string{word}, seen: map[string]bool{word: true}}
+}
+
+func (c *candidateList) add(words ...string) {
+	for _, word := range words {
+		if !c.seen[word] {
+			c.seen[word] = true
+			c.list = append(c.list, word)
+		}
+	}
+}
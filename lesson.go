@@ -0,0 +1,79 @@
+package main
+
+import (
+	"strings"
+	"time"
+
+	"github.com/dpetersen/language-learning/gpt"
+	"github.com/dpetersen/language-learning/history"
+)
+
+// lessonDescription adds the level and grammar we were going for to the
+// story's description, so it's right there when browsing lessons in LingQ.
+func lessonDescription(story gpt.Story) string {
+	var details []string
+	if story.Level != "" {
+		details = append(details, "Nivel "+story.Level)
+	}
+	if len(story.GrammarTargets) > 0 {
+		details = append(details, "Gramática: "+strings.Join(story.GrammarTargets, ", "))
+	}
+
+	if len(details) == 0 {
+		return story.Description
+	}
+	return story.Description + "\n\n" + strings.Join(details, ". ") + "."
+}
+
+func lessonTags(story gpt.Story) []string {
+	var tags []string
+	if story.Level != "" {
+		tags = append(tags, story.Level)
+	}
+	return append(tags, story.GrammarTargets...)
+}
+
+// lessonTranslations are the English translations of each paragraph of the
+// lesson text, title, questions and answers included, if we have them.
+func lessonTranslations(story gpt.Story) []string {
+	if !story.Translated() {
+		return nil
+	}
+
+	var translations []string
+	for _, segment := range story.Segments() {
+		translations = append(translations, segment.Translation)
+	}
+	return translations
+}
+
+// historyEntry is what we remember about the story once it's been imported.
+func historyEntry(story gpt.Story) history.Entry {
+	return history.Entry{
+		Time:        time.Now(),
+		Title:       story.Title,
+		Description: story.Description,
+		Style:       story.Style,
+		Characters:  story.Characters,
+		Summary:     story.Summary,
+		Excerpt:     storyExcerpt(story, 600),
+	}
+}
+
+// storyExcerpt is the opening of the story, cut at the end of a paragraph
+// once it's at least n characters long, or at n characters into a long
+// first paragraph.
+func storyExcerpt(story gpt.Story, n int) string {
+	var excerpt []string
+	var length int
+	for _, paragraph := range story.Paragraphs() {
+		if runes := []rune(paragraph); len(excerpt) == 0 && len(runes) > n {
+			return string(runes[:n]) + "…"
+		}
+		excerpt = append(excerpt, paragraph)
+		if length += len([]rune(paragraph)); length >= n {
+			break
+		}
+	}
+	return strings.Join(excerpt, "\n")
+}
@@ -1,7 +1,9 @@
 package lingq
 
 import (
+	"encoding/json"
 	"fmt"
+	"net/url"
 )
 
 /*
@@ -29,24 +31,47 @@ save: true
 
 const importURL = "https://www.lingq.com/api/v3/es/lessons/import/"
 
-func (c *Client) ImportLesson(textPath, audioPath, thumbnailPath, description, title string) error {
+// Lesson is everything that goes into a lesson import.
+type Lesson struct {
+	TextPath      string
+	AudioPath     string
+	ThumbnailPath string
+	Title         string
+	Description   string
+	// Tags are added to the "gpt" tag every lesson gets.
+	Tags []string
+	// Translations are English translations of each paragraph of the
+	// lesson, in order.
+	Translations []string
+}
+
+func (c *Client) ImportLesson(lesson Lesson) error {
+	// The form sends an empty JSON list when there aren't any.
+	translations, err := json.Marshal(append([]string{}, lesson.Translations...))
+	if err != nil {
+		return fmt.Errorf("marshalling translations: %v", err)
+	}
+
+	form := url.Values{
+		"description": {lesson.Description},
+		"title":       {lesson.Title},
+		// TODO: don't hardcode this?
+		"collection":   {"1467506"},
+		"hasPrice":     {"false"},
+		"isProtected":  {"false"},
+		"isHidden":     {"true"},
+		"language":     {"es"},
+		"status":       {"private"},
+		"tags":         append([]string{"gpt"}, lesson.Tags...),
+		"save":         {"true"},
+		"translations": {string(translations)},
+	}
+
 	response, err := c.newAPIRequest().
-		SetFile("image", thumbnailPath).
-		SetFile("audio", audioPath).
-		SetFile("file", textPath).
-		SetFormData(map[string]string{
-			"description": description,
-			"title":       title,
-			// TODO: don't hardcode this?
-			"collection":  "1467506",
-			"hasPrice":    "false",
-			"isProtected": "false",
-			"isHidden":    "true",
-			"language":    "es",
-			"status":      "private",
-			"tags":        "gpt",
-			"save":        "true",
-		}).
+		SetFile("image", lesson.ThumbnailPath).
+		SetFile("audio", lesson.AudioPath).
+		SetFile("file", lesson.TextPath).
+		SetFormDataFromValues(form).
 		Post(importURL)
 	if err != nil {
 		return fmt.Errorf("making API request: %v", err)
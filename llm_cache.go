@@ -0,0 +1,43 @@
+package main
+
+import (
+	"flag"
+
+	"github.com/dpetersen/language-learning/gpt"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// LLMCache handles the llm-cache command:
+//
+//	llm-cache size
+//	llm-cache prune [-older-than 168h]
+//
+// Pruning defaults to removing whatever is past openai.cache.ttl.
+func LLMCache(args []string) {
+	cache := gpt.NewResponseCache(viper.GetString("openai.cache.path"))
+	if len(args) == 0 {
+		logrus.Fatal("Usage: llm-cache size|prune")
+	}
+
+	switch args[0] {
+	case "size":
+		count, size, err := cache.Size()
+		if err != nil {
+			logrus.WithError(err).Fatal("Measuring LLM cache")
+		}
+		logrus.WithFields(logrus.Fields{"entries": count, "bytes": size}).Info("LLM cache size")
+	case "prune":
+		flags := flag.NewFlagSet("llm-cache prune", flag.ExitOnError)
+		olderThan := flags.Duration("older-than", viper.GetDuration("openai.cache.ttl"), "only remove entries saved this long ago")
+		flags.Parse(args[1:])
+
+		count, size, err := cache.Prune(*olderThan)
+		if err != nil {
+			logrus.WithError(err).Fatal("Pruning LLM cache")
+		}
+		logrus.WithFields(logrus.Fields{"entries": count, "bytes": size}).Info("Pruned LLM cache")
+	default:
+		logrus.WithField("command", args[0]).Fatal("Unknown llm-cache command")
+	}
+}
@@ -2,11 +2,14 @@ package main
 
 import (
 	"encoding/base64"
+	"encoding/json"
 	"os"
 	"strings"
 
 	"github.com/dpetersen/language-learning/audio"
+	"github.com/dpetersen/language-learning/cover"
 	"github.com/dpetersen/language-learning/gpt"
+	"github.com/dpetersen/language-learning/history"
 	"github.com/dpetersen/language-learning/lingq"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/viper"
@@ -15,15 +18,6 @@ import (
 /*
 
 Todo List:
-  - Deal with this error and longer stories:
-     INFO[0000] Checking local database...
-		 INFO[0000] Database not fresh, fetching new words...
-		 INFO[0008] Loaded words                                  count=1435
-		 INFO[0008] Generating story...
-		 INFO[0075] Generated Story                               storyCharacters=3935
-		 INFO[0091] Generating audio...
-		 2024/03/01 17:40:50 rpc error: code = InvalidArgument desc = Either `input.text` or `input.ssml` is longer than the limit of 5000 bytes. This limit is different from quotas. To fix, reduce the byte length of the characters in this request, or consider using the Long Audio API: https://cloud.google.com/text-to-speech/docs/create-audio-text-long-audio-synthesis.
-
 	- Maybe use a higher temperature to write the story, then use a lower
 	temperature to rewrite it using the correct vocabulary. What I'm getting is
 	30-40% unknown words, which is too high. Could even re-rinse the story over
@@ -54,7 +48,60 @@ func init() {
 	viper.AddConfigPath(".")
 
 	viper.SetDefault("log_level", "info")
+	viper.SetDefault("language", "es")
 	viper.SetDefault("lingq.database_path", "lingq-data.json")
+	viper.SetDefault("openai.vocabulary.strategy", "all")
+	viper.SetDefault("openai.vocabulary.known_sample", 500)
+	viper.SetDefault("openai.vocabulary.reinforce_count", 30)
+	viper.SetDefault("openai.vocabulary.new_word_budget", 0)
+	viper.SetDefault("openai.vocabulary.new_word_candidates", 50)
+	viper.SetDefault("openai.vocabulary.lemmatize", true)
+	viper.SetDefault("openai.vocabulary.min_known", 200)
+	viper.SetDefault("openai.level_check", "heuristic")
+	viper.SetDefault("openai.stream", true)
+	viper.SetDefault("openai.cache.mode", gpt.CacheOff)
+	viper.SetDefault("openai.cache.path", ".cache/openai")
+	viper.SetDefault("openai.cache.ttl", "168h")
+	viper.SetDefault("openai.prompt_dir", "")
+	viper.SetDefault("openai.prompt_output_dir", "")
+	viper.SetDefault("openai.evaluation.enabled", false)
+	viper.SetDefault("openai.evaluation.model", "gpt-4o-mini")
+	viper.SetDefault("openai.evaluation.on_failure", "regenerate")
+	viper.SetDefault("openai.evaluation.attempts", 2)
+	viper.SetDefault("openai.translate", true)
+	viper.SetDefault("openai.translation_model", "gpt-4o-mini")
+	viper.SetDefault("quiz.history_path", "quiz-history.json")
+	viper.SetDefault("costs.ledger_path", "costs.jsonl")
+	viper.SetDefault("costs.budget.daily", 0)
+	viper.SetDefault("costs.budget.monthly", 0)
+	viper.SetDefault("history.path", "story-history.json")
+	viper.SetDefault("history.avoid_recent", 10)
+	viper.SetDefault("history.duplicate_threshold", 0.5)
+	viper.SetDefault("history.duplicate_attempts", 2)
+	viper.SetDefault("openai.examples.count", 0)
+	viper.SetDefault("openai.examples.min_rating", 4)
+	viper.SetDefault("dialect.region", "mx")
+	viper.SetDefault("dialect.rewrite", false)
+	viper.SetDefault("series.name", "Language Learning")
+	viper.SetDefault("image.providers", []string{"openai", "local"})
+	viper.SetDefault("image.prompt_source", "excerpt")
+	viper.SetDefault("image.scenes.enabled", false)
+	viper.SetDefault("image.scenes.max_images", 3)
+	viper.SetDefault("image.openai.model", "dall-e-3")
+	viper.SetDefault("image.openai.size", "1024x1024")
+	viper.SetDefault("image.openai.quality", "standard")
+	viper.SetDefault("image.openai.style", "vivid")
+	viper.SetDefault("audio.cache_path", ".cache/audio")
+	viper.SetDefault("audio.concurrency", 4)
+	viper.SetDefault("audio.requests_per_minute", 100)
+	viper.SetDefault("audio.profile", "standard")
+	viper.SetDefault("audio.quiz.include_answers", true)
+	viper.SetDefault("audio.quiz.think_time_base", "4s")
+	viper.SetDefault("audio.quiz.think_time_per_word", "300ms")
+	viper.SetDefault("audio.quiz.think_time_max", "15s")
+	viper.SetDefault("audio.quiz.answer_pause", "2s")
+	viper.SetDefault("audio.quiz.answer_cue", "spoken")
+	viper.SetDefault("audio.quiz.cue_text", "Respuesta:")
 }
 
 func main() {
@@ -66,8 +113,52 @@ func main() {
 		logrus.SetLevel(parsed)
 	}
 
+	// With no command we do what we've always done, which is generate and
+	// import a lesson.
+	command := "generate"
+	var args []string
+	for _, arg := range os.Args[1:] {
+		// --no-cache works with any command, and skips the LLM response cache
+		// for this run.
+		if arg == "--no-cache" {
+			viper.Set("openai.cache.mode", gpt.CacheOff)
+			continue
+		}
+		args = append(args, arg)
+	}
+	if len(args) > 0 {
+		command, args = args[0], args[1:]
+	}
+
+	// logrus.Fatal runs exit handlers, so a run that dies halfway still makes
+	// it into the ledger.
+	logrus.RegisterExitHandler(func() { SaveUsage(command) })
+	defer SaveUsage(command)
+
+	switch command {
+	case "generate":
+		Generate()
+	case "audio-cache":
+		AudioCache(args)
+	case "quiz":
+		Quiz(args)
+	case "costs":
+		Costs(args)
+	case "llm-cache":
+		LLMCache(args)
+	case "rate":
+		Rate(args)
+	default:
+		logrus.WithField("command", command).Fatal("Unknown command")
+	}
+}
+
+func Generate() {
 	words, lingqClient := LoadWords()
-	story := LoadStory(words)
+	story, duplicate := LoadStory(words)
+	ReportNewWords(*story, words)
+	CheckLevel(story)
+	CheckDialect(*story)
 
 	// Write Story to JSON
 	jsonFile, err := os.Create("output.json")
@@ -79,6 +170,18 @@ func main() {
 		logrus.WithError(err).Fatal("Failed to write to file")
 	}
 
+	// Write the vocabulary we asked for, so we can see what the story was
+	// built from
+	if story.Vocabulary != nil {
+		vocabularyJSON, err := json.MarshalIndent(story.Vocabulary, "", "  ")
+		if err != nil {
+			logrus.WithError(err).Fatal("Failed to serialize vocabulary")
+		}
+		if err := os.WriteFile("output.vocabulary.json", vocabularyJSON, 0o644); err != nil {
+			logrus.WithError(err).Fatal("Failed to write vocabulary")
+		}
+	}
+
 	// Write Story to plain text
 	textFile, err := os.Create("output.txt")
 	if err != nil {
@@ -89,6 +192,33 @@ func main() {
 		logrus.WithError(err).Fatal("Failed to write to text file")
 	}
 
+	// Stop short of spending anything more on a story the evaluator didn't
+	// like, but keep what it said about it
+	if story.Evaluation != nil {
+		evaluationJSON, err := json.MarshalIndent(story.Evaluation, "", "  ")
+		if err != nil {
+			logrus.WithError(err).Fatal("Failed to serialize evaluation")
+		}
+		if err := os.WriteFile("output.evaluation.json", evaluationJSON, 0o644); err != nil {
+			logrus.WithError(err).Fatal("Failed to write evaluation")
+		}
+		if len(evaluationProblems(*story)) > 0 {
+			logrus.Warn("Story scored below the evaluation thresholds, not importing it")
+			return
+		}
+	}
+	if duplicate != nil {
+		logrus.WithField("duplicate", duplicate.Title).Warn("Story is too much like one we've already had, not importing it")
+		return
+	}
+
+	// Write the story with its translation interleaved, for when we get stuck
+	if story.Translation != nil {
+		if err := os.WriteFile("output.bilingual.txt", []byte(story.BilingualString()), 0o644); err != nil {
+			logrus.WithError(err).Fatal("Failed to write bilingual text")
+		}
+	}
+
 	// Write thumbnail to PNG
 	imageFile, err := os.Create("output.png")
 	if err != nil {
@@ -102,6 +232,23 @@ func main() {
 	if _, err = imageFile.Write(decodedBytes); err != nil {
 		logrus.WithError(err).Fatal("Failed to write to file")
 	}
+	for _, scene := range story.Scenes {
+		if scene.Image == "" {
+			continue
+		}
+		decoded, err := base64.StdEncoding.DecodeString(scene.Image)
+		if err != nil {
+			logrus.WithError(err).Fatal("Failed to base64 decode scene image")
+		}
+		if err := os.WriteFile(gpt.SceneImagePath(scene.Paragraph), decoded, 0o644); err != nil {
+			logrus.WithError(err).Fatal("Failed to write scene image")
+		}
+	}
+	if story.ThumbnailPrompt != "" {
+		if err := os.WriteFile("output.png.txt", []byte(story.ThumbnailPrompt), 0o644); err != nil {
+			logrus.WithError(err).Fatal("Failed to write image prompt")
+		}
+	}
 
 	// Write audio to MP3
 	logrus.Info("Generating audio...")
@@ -119,15 +266,21 @@ func main() {
 	}
 
 	logrus.Info("Importing lesson to LingQ...")
-	if err := lingqClient.ImportLesson(
-		textFile.Name(),
-		audioFile.Name(),
-		imageFile.Name(),
-		story.Description,
-		story.Title,
-	); err != nil {
+	if err := lingqClient.ImportLesson(lingq.Lesson{
+		TextPath:      textFile.Name(),
+		AudioPath:     audioFile.Name(),
+		ThumbnailPath: imageFile.Name(),
+		Title:         story.Title,
+		Description:   lessonDescription(*story),
+		Tags:          lessonTags(*story),
+		Translations:  lessonTranslations(*story),
+	}); err != nil {
 		logrus.WithError(err).Fatal("Importing lesson to LingQ")
 	}
+
+	if err := history.NewHistory(viper.GetString("history.path")).Append(historyEntry(*story)); err != nil {
+		logrus.WithError(err).Fatal("Saving story to history")
+	}
 }
 
 func GetVarsOrDieTrying() {
@@ -174,7 +327,11 @@ func LoadWords() ([]lingq.Word, *lingq.Client) {
 	return words, client
 }
 
-func LoadStory(words []lingq.Word) *gpt.Story {
+// LoadStory generates a story, or loads one from LOAD_STORY_FILE. If every
+// story we wrote was too much like an old one, the last of them comes back
+// along with the old story it duplicates, so it can be written out without
+// being imported.
+func LoadStory(words []lingq.Word) (*gpt.Story, *history.Entry) {
 	client := gpt.NewClient(
 		viper.GetString("openai.api_key"),
 		viper.GetString("openai.chat_model"),
@@ -183,26 +340,139 @@ func LoadStory(words []lingq.Word) *gpt.Story {
 	if loadStoryFile == "" {
 		logrus.Info("Generating story...")
 
-		story, err := client.CreateStory(words, 3)
+		past, err := history.NewHistory(viper.GetString("history.path")).Load()
 		if err != nil {
-			logrus.WithError(err).Fatal("Creating story")
+			logrus.WithError(err).Fatal("Loading story history")
+		}
+
+		// With evaluation on, a story that scores too low can be replaced with
+		// a new one, up to openai.evaluation.attempts stories in all.
+		attempts := 1
+		if viper.GetBool("openai.evaluation.enabled") {
+			switch onFailure := viper.GetString("openai.evaluation.on_failure"); onFailure {
+			case "skip":
+			case "regenerate":
+				attempts = max(1, viper.GetInt("openai.evaluation.attempts"))
+			default:
+				logrus.WithField("onFailure", onFailure).Fatal("Unknown evaluation failure action")
+			}
 		}
 
-		logrus.WithField("storyCharacters", len(story.Story)).Info("Generated Story")
+		var story *gpt.Story
+		for attempt := 1; ; attempt++ {
+			story, err = client.CreateStory(words, 3, past)
+			if err != nil {
+				logrus.WithError(err).Fatal("Creating story")
+			}
+
+			// Catch a retelling before we spend anything on images and audio.
+			if duplicate, similarity := history.FindDuplicate(historyEntry(*story), past, viper.GetFloat64("history.duplicate_threshold")); duplicate != nil {
+				logrus.WithFields(logrus.Fields{
+					"title":      story.Title,
+					"duplicate":  duplicate.Title,
+					"similarity": similarity,
+					"attempt":    attempt,
+				}).Warn("Story is too much like one we've already had")
+				if attempt >= viper.GetInt("history.duplicate_attempts") {
+					return story, duplicate
+				}
+				continue
+			}
 
-		data, err := client.CreateImage(story.Story)
+			logrus.WithField("storyCharacters", len(story.Story)).Info("Generated Story")
+
+			if !viper.GetBool("openai.evaluation.enabled") || EvaluateStory(client, story) {
+				break
+			}
+			if attempt >= attempts {
+				// Generate writes out what we got and stops short of importing
+				// it, so there's no point illustrating it.
+				return story, nil
+			}
+			logrus.WithField("attempt", attempt).Warn("Story scored too low, writing another")
+		}
+
+		providers, err := cover.ProvidersFromConfig(client)
 		if err != nil {
-			logrus.WithError(err).Fatal("Creating thumbnail image")
+			logrus.WithError(err).Fatal("Configuring image providers")
+		}
+
+		if viper.GetBool("image.scenes.enabled") {
+			IllustrateStory(client, providers, story)
+		}
+
+		// The first scene doubles as the thumbnail, so we only need a cover if
+		// nothing got illustrated.
+		if story.Thumbnail == "" {
+			image, err := cover.Create(providers, *story)
+			if err != nil {
+				logrus.WithError(err).Fatal("Creating thumbnail image")
+			}
+
+			story.Thumbnail = image.Data
+			story.ThumbnailPrompt = image.Prompt
 		}
 
-		story.Thumbnail = data
-		return story
+		if viper.GetBool("openai.translate") {
+			TranslateStory(client, story)
+		}
+		return story, nil
 	} else {
 		logrus.Info("Skipping story generation, loading from file...")
 		story, err := client.LoadStory(loadStoryFile)
 		if err != nil {
 			logrus.WithError(err).Fatal("Loading story from file")
 		}
-		return story
+		return story, nil
+	}
+}
+
+// TranslateStory adds an English translation to the story. A lesson without
+// one is still worth having, so a failure here is only logged.
+func TranslateStory(client *gpt.Client, story *gpt.Story) {
+	logrus.Info("Translating story...")
+	translation, err := client.Translate(*story)
+	if err != nil {
+		logrus.WithError(err).Warn("Translating story")
+		return
+	}
+
+	if err := story.SetTranslation(translation); err != nil {
+		logrus.WithError(err).Warn("Saving translation")
+	}
+}
+
+// IllustrateStory splits the story into scenes and illustrates as many of them
+// as image.scenes.max_images allows. Illustrations are a nice extra, so any
+// failure here is logged and the story carries on without them.
+func IllustrateStory(client *gpt.Client, providers []cover.Provider, story *gpt.Story) {
+	max := viper.GetInt("image.scenes.max_images")
+	if max <= 0 {
+		logrus.WithField("maxImages", max).Info("Not illustrating scenes, there are no images to spare")
+		return
+	}
+
+	logrus.Info("Illustrating scenes...")
+	scenes, err := client.SplitScenes(*story, max)
+	if err != nil {
+		logrus.WithError(err).Warn("Splitting story into scenes")
+		return
+	}
+
+	for i := range scenes {
+		image, err := cover.Illustrate(providers, *story, scenes[i])
+		if err != nil {
+			logrus.WithError(err).WithField("paragraph", scenes[i].Paragraph).Warn("Illustrating scene")
+			continue
+		}
+
+		scenes[i].Image = image.Data
+		if story.Thumbnail == "" {
+			story.Thumbnail = image.Data
+			story.ThumbnailPrompt = image.Prompt
+		}
 	}
+
+	story.Scenes = scenes
+	logrus.WithField("scenes", len(scenes)).Info("Illustrated scenes")
 }
@@ -0,0 +1,84 @@
+// Package quiz grades answers to a story's questions and keeps a history of
+// how each quiz went.
+package quiz
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/dpetersen/language-learning/gpt"
+	"golang.org/x/text/unicode/norm"
+)
+
+// GradeLocally grades the answer to any question that has a single right
+// answer. Free questions need a person, or a model, to judge them, so for
+// those it returns false.
+func GradeLocally(question gpt.Question, answer string) (*gpt.Grade, bool) {
+	var correct bool
+	switch question.Kind() {
+	case gpt.QuestionMultipleChoice:
+		correct = matchesOption(question, answer)
+	case gpt.QuestionTrueFalse:
+		correct = trueOrFalse(answer) == strings.ToLower(strings.TrimSpace(question.Answer))
+	case gpt.QuestionCloze:
+		correct = normalize(answer) == normalize(question.Answer)
+	case gpt.QuestionOrdering:
+		expected, ok := question.Order()
+		given, valid := gpt.ParseOrder(answer, len(question.Options))
+		correct = ok && valid && equal(expected, given)
+	default:
+		return nil, false
+	}
+
+	if correct {
+		return &gpt.Grade{Correct: true, Feedback: "¡Correcto!"}, true
+	}
+	return &gpt.Grade{Feedback: "No exactamente. La respuesta es: " + question.AnswerText()}, true
+}
+
+// matchesOption accepts either the letter of the right option or its text.
+func matchesOption(question gpt.Question, answer string) bool {
+	answer = normalize(answer)
+	for i, option := range question.Options {
+		if normalize(option) != normalize(question.Answer) {
+			continue
+		}
+		return answer == gpt.OptionLetter(i) || answer == normalize(option)
+	}
+	return false
+}
+
+func trueOrFalse(answer string) string {
+	switch normalize(answer) {
+	case "v", "verdadero", "cierto", "si":
+		return gpt.AnswerTrue
+	case "f", "falso", "no":
+		return gpt.AnswerFalse
+	}
+	return ""
+}
+
+// normalize lower cases s and strips accents and punctuation, so that
+// "Cultura." and "cultura" are the same answer. Getting an accent wrong
+// isn't what a comprehension quiz is testing.
+func normalize(s string) string {
+	var result strings.Builder
+	for _, r := range norm.NFD.String(strings.ToLower(strings.TrimSpace(s))) {
+		if unicode.IsLetter(r) || unicode.IsNumber(r) || unicode.IsSpace(r) {
+			result.WriteRune(r)
+		}
+	}
+	return strings.Join(strings.Fields(result.String()), " ")
+}
+
+func equal(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,70 @@
+package quiz
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// History is a file of every quiz we've taken, so we can look back at which
+// lessons we actually understood.
+type History struct {
+	path string
+}
+
+// Attempt is one run through a story's questions.
+type Attempt struct {
+	Title   string
+	Time    time.Time
+	Score   int
+	Total   int
+	Answers []Answer
+}
+
+type Answer struct {
+	Question string
+	Answer   string
+	Correct  bool
+}
+
+func NewHistory(path string) *History {
+	return &History{path: path}
+}
+
+// Load returns every attempt so far, oldest first.
+func (h *History) Load() ([]Attempt, error) {
+	data, err := os.ReadFile(h.path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("reading quiz history: %v", err)
+	}
+
+	var attempts []Attempt
+	if err := json.Unmarshal(data, &attempts); err != nil {
+		return nil, fmt.Errorf("deserializing quiz history: %v", err)
+	}
+
+	return attempts, nil
+}
+
+func (h *History) Append(attempt Attempt) error {
+	attempts, err := h.Load()
+	if err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(append(attempts, attempt), "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshalling JSON: %v", err)
+	}
+
+	if err := os.WriteFile(h.path, data, 0o644); err != nil {
+		return fmt.Errorf("writing quiz history: %v", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/dpetersen/language-learning/gpt"
+	"github.com/dpetersen/language-learning/quiz"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// Quiz handles the quiz command:
+//
+//	quiz <story.json>
+//	quiz history
+func Quiz(args []string) {
+	if len(args) != 1 {
+		logrus.Fatal("Usage: quiz <story.json>|history")
+	}
+
+	history := quiz.NewHistory(viper.GetString("quiz.history_path"))
+	if args[0] == "history" {
+		QuizHistory(history)
+		return
+	}
+
+	client := gpt.NewClient(
+		viper.GetString("openai.api_key"),
+		viper.GetString("openai.chat_model"),
+	)
+	story, err := client.LoadStory(args[0])
+	if err != nil {
+		logrus.WithError(err).Fatal("Loading story from file")
+	}
+
+	fmt.Printf("%s\n\n", story.Title)
+	input := bufio.NewScanner(os.Stdin)
+	attempt := quiz.Attempt{Title: story.Title, Time: time.Now(), Total: len(story.Questions)}
+	for i, question := range story.Questions {
+		fmt.Printf("%d. %s\n> ", i+1, question.Prompt())
+		if !input.Scan() {
+			break
+		}
+		answer := strings.TrimSpace(input.Text())
+
+		grade, graded := quiz.GradeLocally(question, answer)
+		if !graded {
+			if grade, err = client.GradeAnswer(*story, question, answer); err != nil {
+				logrus.WithError(err).Fatal("Grading answer")
+			}
+		}
+
+		fmt.Printf("%s\n\n", grade.Feedback)
+		if grade.Correct {
+			attempt.Score++
+		}
+		attempt.Answers = append(attempt.Answers, quiz.Answer{
+			Question: question.Question,
+			Answer:   answer,
+			Correct:  grade.Correct,
+		})
+	}
+
+	fmt.Printf("Puntuación: %d/%d\n", attempt.Score, attempt.Total)
+	if err := history.Append(attempt); err != nil {
+		logrus.WithError(err).Fatal("Saving quiz history")
+	}
+}
+
+// QuizHistory prints every quiz we've taken, oldest first.
+func QuizHistory(history *quiz.History) {
+	attempts, err := history.Load()
+	if err != nil {
+		logrus.WithError(err).Fatal("Loading quiz history")
+	}
+
+	for _, attempt := range attempts {
+		fmt.Printf("%s  %d/%d  %s\n", attempt.Time.Format("2006-01-02"), attempt.Score, attempt.Total, attempt.Title)
+	}
+}
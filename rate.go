@@ -0,0 +1,41 @@
+package main
+
+import (
+	"flag"
+	"strconv"
+
+	"github.com/dpetersen/language-learning/history"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// Rate handles the rate command, which scores a story from 1 to 5:
+//
+//	rate [-title "El día en el parque"] [-notes "too easy"] <rating>
+//
+// Without a title it rates the most recent story.
+func Rate(args []string) {
+	flags := flag.NewFlagSet("rate", flag.ExitOnError)
+	title := flags.String("title", "", "the title of the story to rate, if not the most recent")
+	notes := flags.String("notes", "", "anything worth remembering about the story")
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		logrus.Fatal("Usage: rate [-title title] [-notes notes] <1-5>")
+	}
+	rating, err := strconv.Atoi(flags.Arg(0))
+	if err != nil {
+		logrus.WithField("rating", flags.Arg(0)).Fatal("Rating must be a number from 1 to 5")
+	}
+
+	entry, err := history.NewHistory(viper.GetString("history.path")).Rate(*title, rating, *notes)
+	if err != nil {
+		logrus.WithError(err).Fatal("Rating story")
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"title":  entry.Title,
+		"style":  entry.Style,
+		"rating": entry.Rating,
+	}).Info("Rated story")
+}
@@ -0,0 +1,104 @@
+package usage
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+const (
+	dayLayout   = "2006-01-02"
+	monthLayout = "2006-01"
+)
+
+// Ledger is a file with a line of JSON for every run that used a paid API.
+type Ledger struct {
+	path string
+}
+
+// Run is everything a single run of the program used, and what it cost.
+type Run struct {
+	Time    time.Time
+	Command string
+	Cost    float64
+	Usage   []Record
+}
+
+func NewLedger(path string) *Ledger {
+	return &Ledger{path: path}
+}
+
+// Load returns every run in the ledger, oldest first.
+func (l *Ledger) Load() ([]Run, error) {
+	file, err := os.Open(l.path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("opening ledger: %v", err)
+	}
+	defer file.Close()
+
+	var runs []Run
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, 16*1024*1024)
+	for scanner.Scan() {
+		var run Run
+		if err := json.Unmarshal(scanner.Bytes(), &run); err != nil {
+			return nil, fmt.Errorf("deserializing ledger: %v", err)
+		}
+		runs = append(runs, run)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading ledger: %v", err)
+	}
+
+	return runs, nil
+}
+
+// Append adds everything recorded in this run to the ledger, if it used
+// anything at all.
+func (l *Ledger) Append(command string) (*Run, error) {
+	run := Run{Time: time.Now(), Command: command, Usage: Records()}
+	if len(run.Usage) == 0 {
+		return nil, nil
+	}
+	run.Cost = total(run.Usage)
+
+	line, err := json.Marshal(run)
+	if err != nil {
+		return nil, fmt.Errorf("marshalling JSON: %v", err)
+	}
+
+	file, err := os.OpenFile(l.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("opening ledger: %v", err)
+	}
+	defer file.Close()
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		return nil, fmt.Errorf("writing ledger: %v", err)
+	}
+
+	return &run, nil
+}
+
+// DailyTotals adds up the cost of runs by day, like 2006-01-02.
+func DailyTotals(runs []Run) map[string]float64 {
+	return totalsBy(runs, dayLayout)
+}
+
+// MonthlyTotals adds up the cost of runs by month, like 2006-01.
+func MonthlyTotals(runs []Run) map[string]float64 {
+	return totalsBy(runs, monthLayout)
+}
+
+func totalsBy(runs []Run, layout string) map[string]float64 {
+	totals := make(map[string]float64)
+	for _, run := range runs {
+		totals[run.Time.Local().Format(layout)] += run.Cost
+	}
+	return totals
+}
@@ -0,0 +1,95 @@
+package usage
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// prices is the price table from the config, in dollars: per million tokens,
+// per image by model, size and quality, and per million characters of speech
+// by voice tier.
+type prices struct {
+	Tokens map[string]struct {
+		Prompt     float64
+		Completion float64
+	}
+	Images map[string]map[string]map[string]float64
+	Speech map[string]float64
+}
+
+var (
+	loadPrices sync.Once
+	table      prices
+
+	// warned keeps us from complaining about the same missing price on every
+	// call.
+	warned sync.Map
+)
+
+func priceTable() prices {
+	loadPrices.Do(func() {
+		if err := viper.UnmarshalKey("costs.prices", &table); err != nil {
+			logrus.WithError(err).Warn("Reading price table, costs will be counted as $0")
+		}
+	})
+	return table
+}
+
+// TokenCost is what a completion costs. The API reports dated model names
+// like gpt-4o-2024-08-06, so the longest price that's a prefix of the model
+// wins.
+func TokenCost(model string, promptTokens, completionTokens int) float64 {
+	var best string
+	for name := range priceTable().Tokens {
+		if strings.HasPrefix(model, name) && len(name) > len(best) {
+			best = name
+		}
+	}
+	if best == "" {
+		missingPrice("tokens", model)
+		return 0
+	}
+
+	price := priceTable().Tokens[best]
+	return (float64(promptTokens)*price.Prompt + float64(completionTokens)*price.Completion) / 1_000_000
+}
+
+// ImageCost is what a generated image costs.
+func ImageCost(model, size, quality string) float64 {
+	price, ok := priceTable().Images[model][size][quality]
+	if !ok {
+		missingPrice("images", model+" "+size+" "+quality)
+	}
+	return price
+}
+
+// SpeechCost is what synthesizing the given number of characters costs.
+// Google counts the SSML tags as well as the text.
+func SpeechCost(voice string, characters int) float64 {
+	tier := voiceTier(voice)
+	price, ok := priceTable().Speech[tier]
+	if !ok {
+		missingPrice("speech", tier)
+	}
+	return float64(characters) * price / 1_000_000
+}
+
+// voiceTier works out what Google charges for a voice from its name, like
+// es-US-Neural2-B.
+func voiceTier(voice string) string {
+	for _, tier := range []string{"Studio", "Neural2", "Wavenet", "Polyglot", "News"} {
+		if strings.Contains(voice, "-"+tier+"-") {
+			return strings.ToLower(tier)
+		}
+	}
+	return "standard"
+}
+
+func missingPrice(kind, name string) {
+	if _, seen := warned.LoadOrStore(kind+" "+name, true); !seen {
+		logrus.WithFields(logrus.Fields{"kind": kind, "name": name}).Warn("No price configured, counting it as $0")
+	}
+}
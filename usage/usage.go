@@ -0,0 +1,184 @@
+// Package usage keeps track of what each run uses from the paid APIs and what
+// that costs, so that spending ends up in a ledger and a run can be stopped
+// before it goes over budget.
+package usage
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+const (
+	KindTokens = "tokens"
+	KindImage  = "image"
+	KindSpeech = "speech"
+)
+
+// Record is a single call to a paid API. Which fields are set depends on the
+// kind of call.
+type Record struct {
+	Time time.Time
+	Kind string
+
+	Model            string `json:",omitempty"`
+	PromptTokens     int    `json:",omitempty"`
+	CompletionTokens int    `json:",omitempty"`
+
+	Size    string `json:",omitempty"`
+	Quality string `json:",omitempty"`
+
+	Tier       string `json:",omitempty"`
+	Characters int    `json:",omitempty"`
+
+	// Cost is in dollars, according to the price table at the time.
+	Cost float64
+}
+
+var (
+	mutex   sync.Mutex
+	records []Record
+	// reserved is the most that calls still in flight could cost, which is
+	// held against the budget until they're recorded.
+	reserved float64
+
+	// spent is what the ledger says we spent before this run, loaded the first
+	// time we check the budget.
+	loadSpent sync.Once
+	spent     map[string]float64
+	spentErr  error
+)
+
+// Reservation holds part of the budget for a call that's in flight, from the
+// time Allow lets it through until what it actually cost is recorded.
+type Reservation struct {
+	amount  float64
+	settled bool
+}
+
+// RecordTokens records a chat completion.
+func (r *Reservation) RecordTokens(model string, promptTokens, completionTokens int) {
+	r.record(Record{
+		Kind:             KindTokens,
+		Model:            model,
+		PromptTokens:     promptTokens,
+		CompletionTokens: completionTokens,
+		Cost:             TokenCost(model, promptTokens, completionTokens),
+	})
+}
+
+// RecordImage records a generated image.
+func (r *Reservation) RecordImage(model, size, quality string) {
+	r.record(Record{
+		Kind:    KindImage,
+		Model:   model,
+		Size:    size,
+		Quality: quality,
+		Cost:    ImageCost(model, size, quality),
+	})
+}
+
+// RecordSpeech records speech synthesized with the given voice.
+func (r *Reservation) RecordSpeech(voice string, characters int) {
+	r.record(Record{
+		Kind:       KindSpeech,
+		Model:      voice,
+		Tier:       voiceTier(voice),
+		Characters: characters,
+		Cost:       SpeechCost(voice, characters),
+	})
+}
+
+// record adds the record and settles the reservation in one go, so there's
+// no moment where the call counts twice or not at all.
+func (r *Reservation) record(record Record) {
+	record.Time = time.Now()
+	logrus.WithField("usage", record).Debug("Recorded API usage")
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	records = append(records, record)
+	r.settle()
+}
+
+// Release gives the reservation back without recording anything, for calls
+// that failed or were answered from a cache. Releasing a reservation that's
+// already been recorded does nothing, so it's safe to defer.
+func (r *Reservation) Release() {
+	mutex.Lock()
+	defer mutex.Unlock()
+	r.settle()
+}
+
+func (r *Reservation) settle() {
+	if !r.settled {
+		reserved -= r.amount
+		r.settled = true
+	}
+}
+
+// Records returns everything recorded so far in this run.
+func Records() []Record {
+	mutex.Lock()
+	defer mutex.Unlock()
+	return append([]Record(nil), records...)
+}
+
+// Cost is what this run has cost so far.
+func Cost() float64 {
+	mutex.Lock()
+	defer mutex.Unlock()
+	return total(records)
+}
+
+func total(records []Record) float64 {
+	var cost float64
+	for _, r := range records {
+		cost += r.Cost
+	}
+	return cost
+}
+
+// Allow returns an error if spending estimate more would take today's or this
+// month's spending over the budget. Callers check before making a request,
+// with the most it could cost, so that we stop before going over rather than
+// after. The estimate is reserved until the call is recorded or released, so
+// calls running at the same time can't go over together either.
+func Allow(estimate float64) (*Reservation, error) {
+	daily := viper.GetFloat64("costs.budget.daily")
+	monthly := viper.GetFloat64("costs.budget.monthly")
+	if daily <= 0 && monthly <= 0 {
+		return &Reservation{}, nil
+	}
+
+	loadSpent.Do(func() {
+		var runs []Run
+		if runs, spentErr = NewLedger(viper.GetString("costs.ledger_path")).Load(); spentErr != nil {
+			return
+		}
+		now := time.Now()
+		spent = map[string]float64{
+			"daily":   DailyTotals(runs)[now.Format(dayLayout)],
+			"monthly": MonthlyTotals(runs)[now.Format(monthLayout)],
+		}
+	})
+	if spentErr != nil {
+		return nil, fmt.Errorf("checking budget: %v", spentErr)
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	cost := total(records) + reserved + estimate
+	if daily > 0 && spent["daily"]+cost > daily {
+		return nil, fmt.Errorf("this would take today's spending to $%.2f, over the daily budget of $%.2f", spent["daily"]+cost, daily)
+	}
+	if monthly > 0 && spent["monthly"]+cost > monthly {
+		return nil, fmt.Errorf("this would take this month's spending to $%.2f, over the monthly budget of $%.2f", spent["monthly"]+cost, monthly)
+	}
+
+	reserved += estimate
+	return &Reservation{amount: estimate}, nil
+}
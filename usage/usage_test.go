@@ -0,0 +1,39 @@
+package usage
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func TestAllowReservesEstimates(t *testing.T) {
+	viper.Set("costs.budget.daily", 1.0)
+	viper.Set("costs.ledger_path", filepath.Join(t.TempDir(), "ledger.jsonl"))
+	t.Cleanup(viper.Reset)
+
+	first, err := Allow(0.6)
+	if err != nil {
+		t.Fatalf("first call: %v", err)
+	}
+	// The first call hasn't been recorded yet, but it might still cost 0.6.
+	if _, err := Allow(0.6); err == nil {
+		t.Fatal("second call allowed while the first is still reserved")
+	}
+
+	first.Release()
+	second, err := Allow(0.6)
+	if err != nil {
+		t.Fatalf("call after releasing: %v", err)
+	}
+
+	// Recording settles the reservation at what the call actually cost.
+	second.record(Record{Kind: KindImage, Cost: 0.3})
+	second.Release()
+	if _, err := Allow(0.6); err != nil {
+		t.Fatalf("call after recording a cheaper one: %v", err)
+	}
+	if _, err := Allow(0.2); err == nil {
+		t.Fatal("call over budget allowed")
+	}
+}